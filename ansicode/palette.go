@@ -0,0 +1,136 @@
+package ansicode
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Fg256 returns the code of the color of the 256 color palette.
+func Fg256(n uint8) string {
+	return EscapeBlock + "38;5;" + strconv.Itoa(int(n)) + EndBlock
+}
+
+func Bg256(n uint8) string {
+	return EscapeBlock + "48;5;" + strconv.Itoa(int(n)) + EndBlock
+}
+
+// FgRGB returns the truecolor code of the color.
+func FgRGB(r, g, b uint8) string {
+	return EscapeBlock + "38;2;" + rgbParams(r, g, b) + EndBlock
+}
+
+func BgRGB(r, g, b uint8) string {
+	return EscapeBlock + "48;2;" + rgbParams(r, g, b) + EndBlock
+}
+
+func rgbParams(r, g, b uint8) string {
+	return strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+}
+
+// RGBTo256 returns the color of the 256 color palette nearest to the
+// color, from its 6x6x6 cube or its grey ramp.
+func RGBTo256(r, g, b uint8) uint8 {
+	// The levels of the cube are 0, 95, 135, 175, 215 and 255.
+	cube := func(x uint8) int {
+		if x < 48 {
+			return 0
+		}
+		if x < 115 {
+			return 1
+		}
+		return (int(x) - 35) / 40
+	}
+	level := func(i int) int {
+		if i == 0 {
+			return 0
+		}
+		return 55 + i*40
+	}
+	ri, gi, bi := cube(r), cube(g), cube(b)
+	cr, cg, cb := level(ri), level(gi), level(bi)
+
+	// The grey ramp is 8, 18, ... 238.
+	avg := (int(r) + int(g) + int(b)) / 3
+	greyIndex := 23
+	if avg < 238 {
+		greyIndex = (avg - 3) / 10
+		if greyIndex < 0 {
+			greyIndex = 0
+		}
+	}
+	grey := 8 + greyIndex*10
+
+	if distance(r, g, b, grey, grey, grey) < distance(r, g, b, cr, cg, cb) {
+		return uint8(232 + greyIndex)
+	}
+	return uint8(16 + 36*ri + 6*gi + bi)
+}
+
+func distance(r, g, b uint8, r2, g2, b2 int) int {
+	dr, dg, db := int(r)-r2, int(g)-g2, int(b)-b2
+	return dr*dr + dg*dg + db*db
+}
+
+// Profile is the set of colors a terminal supports.
+type Profile int
+
+const (
+	ProfileNone Profile = iota
+	// ProfileBasic is the 8 colors, and their bright variants.
+	ProfileBasic
+	Profile256
+	ProfileTrueColor
+)
+
+// ProfileFromEnv returns the profile the terminal declares in COLORTERM
+// and TERM. It doesn't check that the output is a terminal.
+func ProfileFromEnv() Profile {
+	term := os.Getenv("TERM")
+	if term == "dumb" {
+		return ProfileNone
+	}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ProfileTrueColor
+	}
+	if strings.Contains(term, "256color") {
+		return Profile256
+	}
+	return ProfileBasic
+}
+
+// FgRGB returns the code of the color for the profile: truecolor, or
+// the nearest of the 256 or the basic colors. It's empty for
+// ProfileNone.
+func (p Profile) FgRGB(r, g, b uint8) string {
+	switch p {
+	case ProfileTrueColor:
+		return FgRGB(r, g, b)
+	case Profile256:
+		return Fg256(RGBTo256(r, g, b))
+	case ProfileBasic:
+		return EscapeBlock + basicParams(r, g, b) + EndBlock
+	}
+	return ""
+}
+
+// basicParams maps the color to one of the 8 basic colors, by the
+// channels that are more than half lit, made bright if any is mostly lit.
+func basicParams(r, g, b uint8) string {
+	n := 0
+	if r > 127 {
+		n |= 1
+	}
+	if g > 127 {
+		n |= 2
+	}
+	if b > 127 {
+		n |= 4
+	}
+	params := strconv.Itoa(30 + n)
+	if r > 191 || g > 191 || b > 191 {
+		params += ";1"
+	}
+	return params
+}
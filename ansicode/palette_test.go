@@ -0,0 +1,74 @@
+package ansicode
+
+import "testing"
+
+func TestRGBTo256(t *testing.T) {
+	cases := []struct {
+		r, g, b  uint8
+		expected uint8
+	}{
+		{0, 0, 0, 16},
+		{8, 8, 8, 232},
+		// The levels of the cube change halfway between 0 and 95, and
+		// between 95 and 135.
+		{47, 0, 255, 21},
+		{48, 0, 255, 57},
+		{114, 0, 255, 57},
+		{115, 0, 255, 93},
+		{128, 128, 128, 244},
+		{238, 238, 238, 255},
+		{255, 0, 0, 196},
+		{255, 255, 255, 231},
+	}
+	for _, c := range cases {
+		if got := RGBTo256(c.r, c.g, c.b); got != c.expected {
+			t.Errorf("(%d, %d, %d): expected %d, got %d", c.r, c.g, c.b, c.expected, got)
+		}
+	}
+}
+
+func TestProfileFromEnv(t *testing.T) {
+	cases := []struct {
+		term      string
+		colorTerm string
+		expected  Profile
+	}{
+		{"dumb", "truecolor", ProfileNone},
+		{"xterm", "truecolor", ProfileTrueColor},
+		{"xterm", "24bit", ProfileTrueColor},
+		{"xterm-256color", "TrueColor", ProfileTrueColor},
+		{"xterm-256color", "", Profile256},
+		{"screen-256color", "yes", Profile256},
+		{"xterm", "", ProfileBasic},
+		{"", "", ProfileBasic},
+	}
+	for _, c := range cases {
+		t.Setenv("TERM", c.term)
+		t.Setenv("COLORTERM", c.colorTerm)
+		if got := ProfileFromEnv(); got != c.expected {
+			t.Errorf("TERM=%q COLORTERM=%q: expected %d, got %d", c.term, c.colorTerm, c.expected, got)
+		}
+	}
+}
+
+func TestProfileFgRGB(t *testing.T) {
+	cases := []struct {
+		profile  Profile
+		r, g, b  uint8
+		expected string
+	}{
+		{ProfileNone, 255, 0, 0, ""},
+		{ProfileTrueColor, 1, 2, 3, "\x1b[38;2;1;2;3m"},
+		{Profile256, 255, 0, 0, "\x1b[38;5;196m"},
+		{Profile256, 128, 128, 128, "\x1b[38;5;244m"},
+		{ProfileBasic, 0, 0, 0, "\x1b[30m"},
+		{ProfileBasic, 128, 0, 128, "\x1b[35m"},
+		{ProfileBasic, 255, 0, 0, "\x1b[31;1m"},
+		{ProfileBasic, 200, 200, 200, "\x1b[37;1m"},
+	}
+	for _, c := range cases {
+		if got := c.profile.FgRGB(c.r, c.g, c.b); got != c.expected {
+			t.Errorf("%d (%d, %d, %d): expected %q, got %q", c.profile, c.r, c.g, c.b, c.expected, got)
+		}
+	}
+}
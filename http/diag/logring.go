@@ -0,0 +1,230 @@
+package diag
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prasannavl/mchain"
+	"github.com/prasannavl/mchain/hconv"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+// LogRing mounts an endpoint that lists the records kept by the ring
+// sink. The records can be filtered with the query params:
+//
+//	level=warn           records of the level or more severe
+//	field=reqid:1234     records with the field of the value (repeatable)
+//	since=5m, until=...  a time range, as RFC3339 times or durations ago
+//	format=text          the text format instead of JSON
+//
+// Requests that accept "text/event-stream", or have follow=1, are sent
+// the matching records as server-sent events, followed by new ones as
+// they are logged. The write timeout of the diag server ends the stream,
+// and the Last-Event-ID of the reconnect picks up where it left off.
+func LogRing(opts *LogRingOpts) func(*http.ServeMux) {
+	if opts == nil {
+		o := DefaultLogRingOpts(nil)
+		opts = &o
+	}
+	return func(mux *http.ServeMux) {
+		mux.Handle(opts.Path, hconv.ToHttp(LogRingHandlerFunc(opts), nil))
+	}
+}
+
+type LogRingOpts struct {
+	Path string
+	// Sink is the ring that's listed. Without one, the endpoint responds
+	// with 404.
+	Sink *log.RingSink
+}
+
+func DefaultLogRingOpts(sink *log.RingSink) LogRingOpts {
+	return LogRingOpts{
+		Path: "/log/recent",
+		Sink: sink,
+	}
+}
+
+type ringFilter struct {
+	level  log.Level
+	fields [][2]string
+	since  time.Time
+	until  time.Time
+	after  uint64
+}
+
+func (f *ringFilter) match(e *log.RingEntry) bool {
+	if e.Seq <= f.after || e.Meta.Level > f.level {
+		return false
+	}
+	if !f.since.IsZero() && e.Meta.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && e.Meta.Time.After(f.until) {
+		return false
+	}
+	var scratch []byte
+outer:
+	for _, want := range f.fields {
+		for _, fields := range [...][]log.Field{log.GetFields(e.Meta.Logger), e.Fields} {
+			for _, x := range fields {
+				if x.Name != want[0] {
+					continue
+				}
+				scratch = x.AppendText(scratch[:0])
+				if string(scratch) == want[1] {
+					continue outer
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func parseRingFilter(r *http.Request) (*ringFilter, error) {
+	q := r.URL.Query()
+	f := &ringFilter{level: log.Level(^uint(0))}
+	if v := q.Get("level"); v != "" {
+		lvl, err := log.ParseLevel(v)
+		if err != nil {
+			return nil, err
+		}
+		f.level = lvl
+	}
+	for _, v := range q["field"] {
+		i := strings.IndexByte(v, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("field %q is not of the form name:value", v)
+		}
+		f.fields = append(f.fields, [2]string{v[:i], v[i+1:]})
+	}
+	now := time.Now()
+	for _, x := range []struct {
+		key    string
+		target *time.Time
+	}{{"since", &f.since}, {"until", &f.until}} {
+		v := q.Get(x.key)
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err == nil {
+			*x.target = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			*x.target = t
+		} else {
+			return nil, fmt.Errorf("%s %q is neither a duration nor an RFC3339 time", x.key, v)
+		}
+	}
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		f.after, _ = strconv.ParseUint(v, 10, 64)
+	}
+	return f, nil
+}
+
+func LogRingHandlerFunc(opts *LogRingOpts) mchain.HandlerFunc {
+	if opts == nil {
+		o := DefaultLogRingOpts(nil)
+		opts = &o
+	}
+	f := func(w http.ResponseWriter, r *http.Request) error {
+		if opts.Sink == nil {
+			http.Error(w, "no log ring", http.StatusNotFound)
+			return nil
+		}
+		filter, err := parseRingFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		text := r.URL.Query().Get("format") == "text"
+		formatter := log.JSONFormatter
+		if text {
+			formatter = log.DefaultTextFormatter
+		}
+		if r.URL.Query().Get("follow") != "" ||
+			strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			streamRing(w, r, opts.Sink, filter, formatter)
+			return nil
+		}
+
+		var buf bytes.Buffer
+		entries := opts.Sink.Entries()
+		if !text {
+			w.Header().Set("Content-Type", "application/json")
+			buf.WriteByte('[')
+			n := 0
+			for i := range entries {
+				if e := &entries[i]; filter.match(e) {
+					if n > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteString(strings.TrimSuffix(formatter(&e.Record), "\r\n"))
+					n++
+				}
+			}
+			buf.WriteByte(']')
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for i := range entries {
+				if e := &entries[i]; filter.match(e) {
+					buf.WriteString(formatter(&e.Record))
+				}
+			}
+		}
+		w.Write(buf.Bytes())
+		return nil
+	}
+	return f
+}
+
+func streamRing(w http.ResponseWriter, r *http.Request, sink *log.RingSink, filter *ringFilter, formatter func(*log.Record) string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	// Subscribed before the backlog is read, so that nothing in between
+	// is missed. The entries seen twice are skipped by their sequence.
+	entries, cancel := sink.Subscribe(256)
+	defer cancel()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e *log.RingEntry) {
+		if !filter.match(e) {
+			return
+		}
+		fmt.Fprintf(w, "id: %d\n", e.Seq)
+		// Multi-line records have to be split into data lines.
+		msg := strings.TrimSuffix(formatter(&e.Record), "\r\n")
+		for _, line := range strings.Split(strings.Replace(msg, "\r\n", "\n", -1), "\n") {
+			fmt.Fprintf(w, "data: %s\n", line)
+		}
+		w.Write([]byte("\n"))
+		filter.after = e.Seq
+	}
+	backlog := sink.Entries()
+	for i := range backlog {
+		send(&backlog[i])
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-entries:
+			send(&e)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
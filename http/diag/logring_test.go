@@ -0,0 +1,169 @@
+package diag
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/mchain/hconv"
+)
+
+func TestParseRingFilter(t *testing.T) {
+	until, _ := time.Parse(time.RFC3339, "2001-02-03T04:05:06Z")
+	cases := []struct {
+		query  string
+		lastID string
+		check  func(f *ringFilter) bool
+		err    bool
+	}{
+		{"", "", func(f *ringFilter) bool {
+			return f.level == log.Level(^uint(0)) && len(f.fields) == 0 && f.since.IsZero() && f.after == 0
+		}, false},
+		{"level=warn", "", func(f *ringFilter) bool { return f.level == log.WarnLevel }, false},
+		{"level=loud", "", nil, true},
+		{"field=reqid:1&field=user:a:b", "", func(f *ringFilter) bool {
+			return len(f.fields) == 2 && f.fields[0] == [2]string{"reqid", "1"} && f.fields[1] == [2]string{"user", "a:b"}
+		}, false},
+		{"field=reqid", "", nil, true},
+		{"since=5m", "", func(f *ringFilter) bool {
+			ago := time.Since(f.since)
+			return ago >= 5*time.Minute && ago < 6*time.Minute
+		}, false},
+		{"until=2001-02-03T04:05:06Z", "", func(f *ringFilter) bool { return f.until.Equal(until) }, false},
+		{"since=yesterday", "", nil, true},
+		{"", "7", func(f *ringFilter) bool { return f.after == 7 }, false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/log/recent?"+c.query, nil)
+		if c.lastID != "" {
+			r.Header.Set("Last-Event-ID", c.lastID)
+		}
+		f, err := parseRingFilter(r)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+		} else if !c.check(f) {
+			t.Errorf("%q: unexpected filter %+v", c.query, f)
+		}
+	}
+}
+
+func TestRingFilterMatch(t *testing.T) {
+	now := time.Now()
+	l := log.New(log.NopSink{}).With("app", "diag")
+	entry := func(seq uint64, lvl log.Level, at time.Time, fields ...log.Field) *log.RingEntry {
+		e := &log.RingEntry{Seq: seq}
+		e.Meta = log.Metadata{Logger: l, Level: lvl, Time: at}
+		e.Fields = fields
+		return e
+	}
+	all := log.Level(^uint(0))
+	cases := []struct {
+		name   string
+		filter ringFilter
+		entry  *log.RingEntry
+		match  bool
+	}{
+		{"all", ringFilter{level: all}, entry(1, log.DebugLevel, now), true},
+		{"level", ringFilter{level: log.WarnLevel}, entry(1, log.ErrorLevel, now), true},
+		{"less severe", ringFilter{level: log.WarnLevel}, entry(1, log.InfoLevel, now), false},
+		{"seen", ringFilter{level: all, after: 3}, entry(3, log.InfoLevel, now), false},
+		{"new", ringFilter{level: all, after: 3}, entry(4, log.InfoLevel, now), true},
+		{"before since", ringFilter{level: all, since: now}, entry(1, log.InfoLevel, now.Add(-time.Second)), false},
+		{"after until", ringFilter{level: all, until: now}, entry(1, log.InfoLevel, now.Add(time.Second)), false},
+		{"in range", ringFilter{level: all, since: now.Add(-time.Second), until: now}, entry(1, log.InfoLevel, now), true},
+		{"field", ringFilter{level: all, fields: [][2]string{{"id", "7"}}}, entry(1, log.InfoLevel, now, log.Int("id", 7)), true},
+		{"field value", ringFilter{level: all, fields: [][2]string{{"id", "8"}}}, entry(1, log.InfoLevel, now, log.Int("id", 7)), false},
+		{"missing field", ringFilter{level: all, fields: [][2]string{{"user", "a"}}}, entry(1, log.InfoLevel, now), false},
+		{"logger field", ringFilter{level: all, fields: [][2]string{{"app", "diag"}, {"id", "7"}}}, entry(1, log.InfoLevel, now, log.Int("id", 7)), true},
+	}
+	for _, c := range cases {
+		if got := c.filter.match(c.entry); got != c.match {
+			t.Errorf("%s: expected %v, got %v", c.name, c.match, got)
+		}
+	}
+}
+
+// readEvent reads a server-sent event, returning its id and data lines.
+func readEvent(t *testing.T, r *bufio.Reader) (id string, data []string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return id, data
+		case strings.HasPrefix(line, "id: "):
+			id = line[len("id: "):]
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, line[len("data: "):])
+		}
+	}
+}
+
+func TestLogRingStream(t *testing.T) {
+	sink := log.NewRingSink(nil)
+	l := log.New(sink)
+	log.SetFlags(l, 0)
+	l.Info("one")
+	l.Info("two\nlines")
+	l.Debug("hidden")
+
+	opts := DefaultLogRingOpts(sink)
+	srv := httptest.NewServer(hconv.ToHttp(LogRingHandlerFunc(&opts), nil))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", srv.URL+"/log/recent?level=info&format=text", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	r := bufio.NewReader(res.Body)
+
+	// The backlog after the last event id, split into data lines.
+	id, data := readEvent(t, r)
+	if id != "2" || len(data) != 2 || !strings.Contains(data[0], "two") || data[1] != "lines" {
+		t.Fatalf("unexpected event %s %q", id, data)
+	}
+	// Followed by the new records that match.
+	l.Debug("hidden too")
+	l.Warn("three")
+	id, data = readEvent(t, r)
+	if id != "5" || len(data) != 1 || !strings.Contains(data[0], "three") {
+		t.Fatalf("unexpected event %s %q", id, data)
+	}
+}
+
+func TestLogRingNilOpts(t *testing.T) {
+	mux := http.NewServeMux()
+	LogRing(nil)(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/log/recent", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a ring, got %d", w.Code)
+	}
+}
@@ -24,6 +24,7 @@ func LogLevelSwitcher(opts *LogSwitcherOpts) func(*http.ServeMux) {
 type LogSwitcherOpts struct {
 	Path       string
 	LevelParam string
+	NameParam  string
 	FlushParam string
 }
 
@@ -31,6 +32,7 @@ func DefaultLogSwitcherOpts() LogSwitcherOpts {
 	return LogSwitcherOpts{
 		Path:       "/log",
 		LevelParam: "set-level",
+		NameParam:  "name",
 		FlushParam: "flush",
 	}
 }
@@ -43,15 +45,19 @@ func LogLevelSwitchHandlerFunc(opts *LogSwitcherOpts) mchain.HandlerFunc {
 	f := func(w http.ResponseWriter, r *http.Request) error {
 		flush := r.URL.Query().Get(opts.FlushParam)
 		lvl := r.URL.Query().Get(opts.LevelParam)
+		name, named := r.URL.Query()[opts.NameParam]
 
 		if lvl != "" {
 			lvl = strings.ToLower(strings.TrimSpace(lvl))
-			level := log.LogLevelFromString(lvl)
-			if log.IsValidLevel(level) {
-				log.SetFilter(log.GetLogger(), log.LogFilterForLevel(level))
-			} else {
-				w.WriteHeader(http.StatusBadRequest)
+			if named && lvl == "reset" {
+				log.ResetLevel(strings.TrimSpace(name[0]))
+			} else if level, err := log.ParseLevel(lvl); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return nil
+			} else if named {
+				log.SetLevel(strings.TrimSpace(name[0]), level)
+			} else {
+				log.SetFilterLevel(log.GetLogger(), level)
 			}
 		}
 
@@ -39,7 +39,7 @@ func (f *FileServerEx) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		e := err.(*Err)
 
-		l := log.With("path", r.URL.Path).
+		l := log.Named("http.fileserver").With("path", r.URL.Path).
 			With("status", e.Code())
 		if !errutils.HasMessage(e) {
 			l = l.With("cause", e.Cause())
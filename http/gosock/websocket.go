@@ -83,7 +83,7 @@ func DefaultWebSocketServerOptions() WebSocketServerOptions {
 			ReadBufferSize:    256,
 			WriteBufferSize:   256,
 			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
-				log.Errorf("websocket: status: %v, %v", status, reason)
+				log.Named("gosock").Errorf("websocket: status: %v, %v", status, reason)
 				w.WriteHeader(status)
 			},
 		},
@@ -42,7 +42,7 @@ func (server *XNetWebSocketServer) handleConn(ws *websocket.Conn) {
 	ws.PayloadType = websocket.BinaryFrame // websocket.TextFrame;
 	s.Adopt(ws)
 	if err := s.Handshake(); err != nil {
-		log.Tracef("gosock: %v", err)
+		log.Named("gosock").Tracef("gosock: %v", err)
 		return
 	}
 	if server.onAccept != nil {
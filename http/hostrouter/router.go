@@ -71,12 +71,12 @@ func (h *HostRouter) Build() mchain.Handler {
 		hh := func(w http.ResponseWriter, r *http.Request) error {
 			hostname := h.HostFunc(r)
 			if handler, ok := items[hostname]; ok {
-				log.Trace("host-router: host: " + hostname)
+				log.Named("hostrouter").Trace("host-router: host: " + hostname)
 				return handler.ServeHTTP(w, r)
 			}
 			for _, x := range h.PatternItems {
 				if x.matcher.Match(hostname) {
-					log.Trace("host-router: match: - " + hostname + " pattern: " + x.pattern)
+					log.Named("hostrouter").Trace("host-router: match: - " + hostname + " pattern: " + x.pattern)
 					return x.handler.ServeHTTP(w, r)
 				}
 			}
@@ -89,13 +89,13 @@ func (h *HostRouter) Build() mchain.Handler {
 		hostname := h.HostFunc(r)
 		for _, x := range items {
 			if x.host == hostname {
-				log.Trace("host-router: host: " + hostname)
+				log.Named("hostrouter").Trace("host-router: host: " + hostname)
 				return x.handler.ServeHTTP(w, r)
 			}
 		}
 		for _, x := range h.PatternItems {
 			if x.matcher.Match(hostname) {
-				log.Trace("host-router: match: - " + hostname + " pattern: " + x.pattern)
+				log.Named("hostrouter").Trace("host-router: match: - " + hostname + " pattern: " + x.pattern)
 				return x.handler.ServeHTTP(w, r)
 			}
 		}
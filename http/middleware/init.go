@@ -9,6 +9,8 @@ import (
 	"github.com/prasannavl/mchain"
 )
 
+// InitMiddleware sets up the request context with the given logger.
+// When it's nil, the global logger at the time of the request is used.
 func InitMiddleware(l *log.Logger) mchain.Middleware {
 	m := func(next mchain.Handler) mchain.Handler {
 		f := func(w http.ResponseWriter, r *http.Request) error {
@@ -18,8 +20,12 @@ func InitMiddleware(l *log.Logger) mchain.Middleware {
 					ww.Flush()
 				}
 			}()
+			logger := l
+			if logger == nil {
+				logger = log.GetLogger()
+			}
 			err := next.ServeHTTP(ww, reqcontext.WithContext(r, &reqcontext.RequestContext{
-				Logger: *l,
+				Logger: logger,
 			}))
 			return err
 		}
@@ -20,11 +20,12 @@ func LoggerMiddleware(requestLogLevel log.Level) mchain.Middleware {
 			ww := w.(writer.ResponseWriter)
 			startTime := time.Now()
 			err := next.ServeHTTP(w, r)
-			ctx := reqcontext.FromRequest(r)
-			logger := &ctx.Logger
+			logger := reqcontext.GetRequestLogger(r)
 			if err != nil {
 				LogError(logger, err)
-				LogErrorStack(logger, ctx.ErrorStacks...)
+				if ctx := reqcontext.FromRequest(r); ctx != nil {
+					LogErrorStack(logger, ctx.ErrorStacks...)
+				}
 			}
 			sizeRaw := ww.BytesWritten()
 			if sizeRaw > 0 {
@@ -55,6 +56,7 @@ func LoggerMiddleware(requestLogLevel log.Level) mchain.Middleware {
 }
 
 func LogError(logger *log.Logger, e interface{}) {
+	logger = logger.WithCallerSkip(1)
 	if err, ok := e.(error); ok {
 		iter := errutils.MakeIteratorLimited(err, 10)
 		for {
@@ -72,6 +74,7 @@ func LogError(logger *log.Logger, e interface{}) {
 }
 
 func LogErrorStack(logger *log.Logger, stacks ...[]byte) {
+	logger = logger.WithCallerSkip(1)
 	var i = 0
 	for _, stack := range stacks {
 		if len(stack) > 0 {
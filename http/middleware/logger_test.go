@@ -0,0 +1,119 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/http/middleware"
+	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/go-gluons/log/logtest"
+	"github.com/prasannavl/mchain"
+)
+
+func serve(h mchain.Handler, r *http.Request) {
+	h = middleware.RequestIDMiddleware(true)(h)
+	h = middleware.InitMiddleware(nil)(h)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	s := logtest.Install(t)
+	const reqID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	ok := mchain.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("hello"))
+		return nil
+	})
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r.Header.Set(middleware.RequestIDHeaderKey, reqID)
+	serve(middleware.LoggerMiddleware(log.InfoLevel)(ok), r)
+
+	s.AssertLogged(t,
+		logtest.Level(log.InfoLevel),
+		logtest.MessageContains("GET 200"),
+		logtest.MessageContains("/hello"),
+		logtest.Field("reqid", reqID))
+	s.AssertNotLogged(t, logtest.LevelAtLeast(log.ErrorLevel))
+
+	s.Reset()
+	failing := mchain.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	})
+	serve(middleware.LoggerMiddleware(log.InfoLevel)(failing), httptest.NewRequest("GET", "/fail", nil))
+
+	s.AssertLogged(t,
+		logtest.Level(log.ErrorLevel),
+		logtest.MessageContains("boom"),
+		logtest.HasField("reqid"))
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	s := logtest.Install(t)
+	logRequest := mchain.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		log.FromContext(r.Context()).Info("handled")
+		return nil
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(middleware.RequestIDHeaderKey, "not-a-uuid")
+	serve(logRequest, r)
+	s.AssertNotLogged(t, logtest.Message("handled"))
+
+	serve(logRequest, httptest.NewRequest("GET", "/", nil))
+	s.AssertLogged(t, logtest.Message("handled"), logtest.HasField("reqid"))
+}
+
+func TestInitMiddlewareFilter(t *testing.T) {
+	l, s := logtest.NewLogger()
+	logRequest := mchain.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		log.FromContext(r.Context()).Info("handled")
+		return nil
+	})
+	h := middleware.RequestIDMiddleware(false)(logRequest)
+	h = middleware.InitMiddleware(l)(h)
+
+	// The requests use the logger itself, so changing its filter while
+	// they're served doesn't race, and applies to them right away.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				log.SetFilterLevel(l, log.WarnLevel)
+			} else {
+				log.SetFilterLevel(l, log.InfoLevel)
+			}
+		}
+	}()
+	var requests sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			for j := 0; j < 50; j++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+		}()
+	}
+	requests.Wait()
+	close(done)
+	wg.Wait()
+
+	s.Reset()
+	log.SetFilterLevel(l, log.WarnLevel)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	s.AssertNotLogged(t, logtest.Message("handled"))
+	log.SetFilterLevel(l, log.InfoLevel)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	s.AssertLogged(t, logtest.Message("handled"), logtest.HasField("reqid"))
+}
@@ -22,7 +22,7 @@ func RequestIDMiddleware(reuseUpstreamID bool) mchain.Middleware {
 
 func requestIDInitOrFailMiddleware(next mchain.Handler) mchain.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) error {
-		err := requestIDInitOrFailHandler(r, reqcontext.FromRequest(r))
+		r, err := requestIDInitOrFailHandler(r, reqcontext.FromRequest(r))
 		if err != nil {
 			return err
 		}
@@ -33,7 +33,7 @@ func requestIDInitOrFailMiddleware(next mchain.Handler) mchain.Handler {
 
 func requestIDInitOrReuseMiddleware(next mchain.Handler) mchain.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) error {
-		err := requestIDInitOrReuseHandler(r, reqcontext.FromRequest(r))
+		r, err := requestIDInitOrReuseHandler(r, reqcontext.FromRequest(r))
 		if err != nil {
 			return err
 		}
@@ -42,27 +42,25 @@ func requestIDInitOrReuseMiddleware(next mchain.Handler) mchain.Handler {
 	return mchain.HandlerFunc(f)
 }
 
-func requestIDInitOrFailHandler(r *http.Request, c *reqcontext.RequestContext) error {
+func requestIDInitOrFailHandler(r *http.Request, c *reqcontext.RequestContext) (*http.Request, error) {
 	if _, ok := r.Header[RequestIDHeaderKey]; ok {
 		msg := fmt.Sprintf("illegal header (%s)", RequestIDHeaderKey)
-		return httperror.New(400, msg, true)
+		return r, httperror.New(400, msg, true)
 	}
 	c.RequestID, _ = uuid.NewRandom()
-	c.Logger = *c.Logger.With("reqid", c.RequestID)
-	return nil
+	return reqcontext.SetLogger(r, c.Logger.With("reqid", c.RequestID)), nil
 }
 
-func requestIDInitOrReuseHandler(r *http.Request, c *reqcontext.RequestContext) error {
+func requestIDInitOrReuseHandler(r *http.Request, c *reqcontext.RequestContext) (*http.Request, error) {
 	var uid uuid.UUID
 	if ok, err := requestIDParseFromHeaders(&uid, r.Header); err != nil {
 		msg := fmt.Sprintf("malformed header (%s)", RequestIDHeaderKey)
-		return httperror.NewWithCause(400, msg, err, true)
+		return r, httperror.NewWithCause(400, msg, err, true)
 	} else if !ok {
 		uid, _ = uuid.NewRandom()
 	}
 	c.RequestID = uid
-	c.Logger = *c.Logger.With("reqid", c.RequestID)
-	return nil
+	return reqcontext.SetLogger(r, c.Logger.With("reqid", c.RequestID)), nil
 }
 
 func requestIDParseFromHeaders(target *uuid.UUID, h http.Header) (ok bool, err error) {
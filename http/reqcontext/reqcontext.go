@@ -10,7 +10,7 @@ import (
 
 type RequestContext struct {
 	RequestID   uuid.UUID
-	Logger      log.Logger
+	Logger      *log.Logger
 	ErrorStacks []errorStack
 }
 
@@ -19,22 +19,30 @@ type errorStack = []byte
 type requestContextKey struct{}
 
 func FromRequest(r *http.Request) *RequestContext {
-	return (r.Context().Value(requestContextKey{})).(*RequestContext)
+	ctx, _ := (r.Context().Value(requestContextKey{})).(*RequestContext)
+	return ctx
 }
 
+// WithContext also makes the logger of the request context available
+// through log.FromContext.
 func WithContext(r *http.Request, ctx *RequestContext) *http.Request {
 	c := context.WithValue(r.Context(), requestContextKey{}, ctx)
+	c = log.NewContext(c, ctx.Logger)
 	return r.WithContext(c)
 }
 
-func GetRequestLogger(r *http.Request) *log.Logger {
-	var logger *log.Logger
-	ctx := FromRequest(r)
-	if ctx != nil {
-		logger = &ctx.Logger
+// SetLogger replaces the logger of the request context of r, and returns
+// r with it available through log.FromContext too.
+func SetLogger(r *http.Request, l *log.Logger) *http.Request {
+	if ctx := FromRequest(r); ctx != nil {
+		ctx.Logger = l
 	}
-	if logger != nil {
-		return logger
+	return r.WithContext(log.NewContext(r.Context(), l))
+}
+
+func GetRequestLogger(r *http.Request) *log.Logger {
+	if ctx := FromRequest(r); ctx != nil && ctx.Logger != nil {
+		return ctx.Logger
 	}
-	return log.GetLogger()
+	return log.FromContext(r.Context())
 }
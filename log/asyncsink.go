@@ -0,0 +1,202 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the logging goroutine wait for space in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the incoming record.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued record to make room.
+	OverflowDropOldest
+	// OverflowDropBelowLevel discards incoming records that are less severe
+	// than AsyncSinkOpts.PreserveLevel, and blocks for the rest.
+	OverflowDropBelowLevel
+)
+
+type AsyncSinkOpts struct {
+	QueueSize     int
+	Policy        OverflowPolicy
+	PreserveLevel Level
+}
+
+func DefaultAsyncSinkOpts() AsyncSinkOpts {
+	return AsyncSinkOpts{
+		QueueSize:     1024,
+		Policy:        OverflowBlock,
+		PreserveLevel: WarnLevel,
+	}
+}
+
+// AsyncSink hands records off to a bounded queue that is drained into
+// the inner sink by a single background goroutine. The inner sink is
+// never called concurrently, so it doesn't have to be synchronized.
+type AsyncSink struct {
+	inner Sink
+	opts  AsyncSinkOpts
+
+	m        sync.Mutex
+	notEmpty sync.Cond
+	notFull  sync.Cond
+	drained  sync.Cond
+	queue    []Record
+	head     int
+	count    int
+	// enqueued is the sequence of the last record queued, and the
+	// records in the queue are the count ones up to it.
+	enqueued    uint64
+	inFlight    bool
+	inFlightSeq uint64
+	closed      bool
+	done        chan struct{}
+
+	innerM  sync.Mutex
+	dropped uint64
+}
+
+func NewAsyncSink(inner Sink, opts *AsyncSinkOpts) *AsyncSink {
+	if opts == nil {
+		o := DefaultAsyncSinkOpts()
+		opts = &o
+	}
+	size := opts.QueueSize
+	if size < 1 {
+		size = 1
+	}
+	s := &AsyncSink{
+		inner: inner,
+		opts:  *opts,
+		queue: make([]Record, size),
+		done:  make(chan struct{}),
+	}
+	s.notEmpty.L = &s.m
+	s.notFull.L = &s.m
+	s.drained.L = &s.m
+	go s.run()
+	return s
+}
+
+func (s *AsyncSink) Log(r *Record) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for !s.closed && s.count == len(s.queue) {
+		switch s.opts.Policy {
+		case OverflowDropNewest:
+			s.drop()
+			return
+		case OverflowDropOldest:
+			s.queue[s.head] = Record{}
+			s.head = (s.head + 1) % len(s.queue)
+			s.count--
+			s.drop()
+			s.drained.Broadcast()
+		case OverflowDropBelowLevel:
+			if r.Meta.Level > s.opts.PreserveLevel {
+				s.drop()
+				return
+			}
+			s.notFull.Wait()
+		default:
+			s.notFull.Wait()
+		}
+	}
+	if s.closed {
+		s.drop()
+		return
+	}
+	rec := *r
+	if len(r.Fields) > 0 {
+		rec.Fields = append([]Field(nil), r.Fields...)
+	}
+	if len(r.Stack) > 0 {
+		rec.Stack = append([]Frame(nil), r.Stack...)
+	}
+	s.queue[(s.head+s.count)%len(s.queue)] = rec
+	s.count++
+	s.enqueued++
+	s.notEmpty.Signal()
+}
+
+// Flush waits until every record queued before the call has been
+// written to the inner sink, and then flushes it. Records queued after
+// the call aren't waited for, so that it returns under steady logging.
+func (s *AsyncSink) Flush() {
+	s.m.Lock()
+	target := s.enqueued
+	for s.pendingUpTo(target) {
+		s.drained.Wait()
+	}
+	s.m.Unlock()
+	s.innerM.Lock()
+	s.inner.Flush()
+	s.innerM.Unlock()
+}
+
+// Close stops accepting records, drains the queue into the inner sink
+// and flushes it. Records logged after Close are counted as dropped.
+func (s *AsyncSink) Close() {
+	s.m.Lock()
+	if !s.closed {
+		s.closed = true
+		s.notEmpty.Broadcast()
+		s.notFull.Broadcast()
+	}
+	s.m.Unlock()
+	<-s.done
+	s.innerM.Lock()
+	s.inner.Flush()
+	s.innerM.Unlock()
+}
+
+// pendingUpTo reports whether any of the records up to the sequence are
+// still queued or being written.
+func (s *AsyncSink) pendingUpTo(seq uint64) bool {
+	if s.inFlight && s.inFlightSeq <= seq {
+		return true
+	}
+	return s.count > 0 && s.enqueued-uint64(s.count)+1 <= seq
+}
+
+// Dropped returns the total number of records discarded so far.
+func (s *AsyncSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *AsyncSink) drop() {
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+func (s *AsyncSink) run() {
+	defer close(s.done)
+	s.m.Lock()
+	for {
+		for s.count == 0 && !s.closed {
+			s.notEmpty.Wait()
+		}
+		if s.count == 0 {
+			s.m.Unlock()
+			return
+		}
+		r := s.queue[s.head]
+		s.inFlightSeq = s.enqueued - uint64(s.count) + 1
+		s.queue[s.head] = Record{}
+		s.head = (s.head + 1) % len(s.queue)
+		s.count--
+		s.inFlight = true
+		s.notFull.Signal()
+		s.m.Unlock()
+
+		s.innerM.Lock()
+		s.inner.Log(&r)
+		s.innerM.Unlock()
+
+		s.m.Lock()
+		s.inFlight = false
+		s.drained.Broadcast()
+	}
+}
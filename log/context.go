@@ -0,0 +1,21 @@
+package log
+
+import "context"
+
+type loggerContextKey struct{}
+
+// NewContext returns a copy of the parent context that carries the logger.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the global
+// logger if there's none.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	return GetLogger()
+}
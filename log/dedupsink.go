@@ -0,0 +1,194 @@
+package log
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DedupSinkOpts struct {
+	// Window is how long the repeats of a record are collected, before
+	// they're logged as one.
+	Window time.Duration
+	// Consecutive only collapses the repeats that follow one another, so
+	// that any other record ends the repeats before it's logged.
+	Consecutive bool
+	// MaxKeys is the number of distinct records tracked in a window.
+	// Records beyond it are passed on as they are.
+	MaxKeys int
+}
+
+func DefaultDedupSinkOpts() DedupSinkOpts {
+	return DedupSinkOpts{
+		Window:  10 * time.Second,
+		MaxKeys: 1000,
+	}
+}
+
+// DedupSink collapses identical records, of the same level, message and
+// fields. The first of them is passed on to the inner sink right away,
+// and the ones that repeat it within the window are counted instead.
+// At the end of the window, and on Flush, a record is logged for each
+// that repeated, with the fields "repeated", "first" and "last" added,
+// for the number of repeats, and the times of the first record and of
+// the last repeat.
+type DedupSink struct {
+	inner Sink
+	opts  DedupSinkOpts
+
+	m       sync.Mutex
+	entries map[string]*dedupEntry
+	seq     uint64
+	timer   *time.Timer
+}
+
+type dedupEntry struct {
+	record   Record
+	seq      uint64
+	repeated int
+	first    time.Time
+	last     time.Time
+}
+
+func NewDedupSink(inner Sink, opts *DedupSinkOpts) *DedupSink {
+	if opts == nil {
+		o := DefaultDedupSinkOpts()
+		opts = &o
+	}
+	s := &DedupSink{
+		inner:   inner,
+		opts:    *opts,
+		entries: make(map[string]*dedupEntry),
+	}
+	defaults := DefaultDedupSinkOpts()
+	if s.opts.Window <= 0 {
+		s.opts.Window = defaults.Window
+	}
+	if s.opts.MaxKeys <= 0 {
+		s.opts.MaxKeys = defaults.MaxKeys
+	}
+	return s
+}
+
+func (s *DedupSink) Log(r *Record) {
+	now := r.Meta.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	msg := FormatMessage(r)
+	key := dedupKey(r, msg)
+
+	s.m.Lock()
+	if e := s.entries[key]; e != nil {
+		e.repeated++
+		e.last = now
+		s.m.Unlock()
+		return
+	}
+	var summaries []Record
+	if s.opts.Consecutive {
+		summaries = s.collect()
+	}
+	if len(s.entries) < s.opts.MaxKeys {
+		// Kept past the call, so the message is rendered and the
+		// fields are copied.
+		x := *r
+		x.Format = msg
+		x.Args = nil
+		x.Fields = append([]Field(nil), r.Fields...)
+		x.Stack = nil
+		s.seq++
+		s.entries[key] = &dedupEntry{record: x, seq: s.seq, first: now}
+		if s.timer == nil {
+			var t *time.Timer
+			// Read by endWindow under s.m, so it's set by then.
+			t = time.AfterFunc(s.opts.Window, func() { s.endWindow(&t) })
+			s.timer = t
+		}
+	}
+	s.m.Unlock()
+
+	for i := range summaries {
+		s.inner.Log(&summaries[i])
+	}
+	s.inner.Log(r)
+}
+
+// Flush logs the repeats collected so far, and flushes the inner sink.
+func (s *DedupSink) Flush() {
+	s.m.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	summaries := s.collect()
+	s.m.Unlock()
+	for i := range summaries {
+		s.inner.Log(&summaries[i])
+	}
+	s.inner.Flush()
+}
+
+func (s *DedupSink) endWindow(t **time.Timer) {
+	s.m.Lock()
+	// A timer that was stopped too late, by Flush, ends nothing, since
+	// there may be another one in its place.
+	if s.timer != *t {
+		s.m.Unlock()
+		return
+	}
+	s.timer = nil
+	summaries := s.collect()
+	s.m.Unlock()
+	for i := range summaries {
+		s.inner.Log(&summaries[i])
+	}
+}
+
+// collect returns the records of the entries that repeated, in the order
+// they were first seen, and starts over with no entries.
+func (s *DedupSink) collect() []Record {
+	var repeated []*dedupEntry
+	for _, e := range s.entries {
+		if e.repeated > 0 {
+			repeated = append(repeated, e)
+		}
+	}
+	sort.Slice(repeated, func(i, j int) bool {
+		return repeated[i].seq < repeated[j].seq
+	})
+	var res []Record
+	for _, e := range repeated {
+		r := e.record
+		r.Meta.Time = e.last
+		r.Fields = append(r.Fields,
+			Int("repeated", e.repeated),
+			Time("first", e.first),
+			Time("last", e.last))
+		res = append(res, r)
+	}
+	if len(s.entries) > 0 {
+		s.entries = make(map[string]*dedupEntry)
+	}
+	return res
+}
+
+func dedupKey(r *Record, msg string) string {
+	var b strings.Builder
+	b.WriteString(strconv.FormatUint(uint64(r.Meta.Level), 10))
+	b.WriteByte(0)
+	b.WriteString(msg)
+	var scratch []byte
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			b.WriteByte(0)
+			b.WriteString(x.Name)
+			b.WriteByte('=')
+			scratch = x.AppendText(scratch[:0])
+			b.Write(scratch)
+		}
+	}
+	return b.String()
+}
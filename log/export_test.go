@@ -0,0 +1,13 @@
+package log
+
+import "net"
+
+// UnregisterLevel removes a custom level, so that tests can register it
+// again when they're repeated.
+var UnregisterLevel = unregisterLevel
+
+// SetNetworkSinkDial makes the sinks created with opts connect through
+// dial instead of the network.
+func SetNetworkSinkDial(opts *NetworkSinkOpts, dial func(network, address string) (net.Conn, error)) {
+	opts.dial = dial
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type FieldKind uint8
+
+const (
+	AnyKind FieldKind = iota
+	StringKind
+	IntKind
+	BoolKind
+	DurationKind
+	TimeKind
+	ErrorKind
+)
+
+// Field is a named value attached to a logger or to a single record.
+//
+// Fields created with the typed constructors keep scalars unboxed, so
+// that they can be passed around and rendered without allocations.
+// Whatever the kind, the value is read with Interface or AppendText.
+type Field struct {
+	Name string
+	// Value is only set for AnyKind and ErrorKind, which is also the
+	// kind of a Field{Name: name, Value: value} literal.
+	Value interface{}
+	Kind  FieldKind
+	num   int64
+	str   string
+	loc   *time.Location
+}
+
+func String(name string, value string) Field {
+	return Field{Name: name, Kind: StringKind, str: value}
+}
+
+func Int(name string, value int) Field {
+	return Field{Name: name, Kind: IntKind, num: int64(value)}
+}
+
+func Int64(name string, value int64) Field {
+	return Field{Name: name, Kind: IntKind, num: value}
+}
+
+func Bool(name string, value bool) Field {
+	var n int64
+	if value {
+		n = 1
+	}
+	return Field{Name: name, Kind: BoolKind, num: n}
+}
+
+func Duration(name string, value time.Duration) Field {
+	return Field{Name: name, Kind: DurationKind, num: int64(value)}
+}
+
+func Time(name string, value time.Time) Field {
+	// UnixNano is only defined within this range.
+	if y := value.Year(); y < 1678 || y > 2261 {
+		return Any(name, value)
+	}
+	return Field{Name: name, Kind: TimeKind, num: value.UnixNano(), loc: value.Location()}
+}
+
+func Err(err error) Field {
+	return Field{Name: "error", Kind: ErrorKind, Value: err}
+}
+
+func Any(name string, value interface{}) Field {
+	return Field{Name: name, Kind: AnyKind, Value: value}
+}
+
+// Interface returns the value of the field as an interface, boxing
+// it if it was created with a typed constructor.
+func (f Field) Interface() interface{} {
+	switch f.Kind {
+	case StringKind:
+		return f.str
+	case IntKind:
+		return f.num
+	case BoolKind:
+		return f.num != 0
+	case DurationKind:
+		return time.Duration(f.num)
+	case TimeKind:
+		return f.time()
+	}
+	return f.Value
+}
+
+// AppendText appends the textual representation of the value to dst.
+func (f Field) AppendText(dst []byte) []byte {
+	switch f.Kind {
+	case StringKind:
+		return append(dst, f.str...)
+	case IntKind:
+		return strconv.AppendInt(dst, f.num, 10)
+	case BoolKind:
+		return strconv.AppendBool(dst, f.num != 0)
+	case DurationKind:
+		return append(dst, time.Duration(f.num).String()...)
+	case TimeKind:
+		return f.time().AppendFormat(dst, time.RFC3339Nano)
+	case ErrorKind:
+		if f.Value == nil {
+			return append(dst, "nil"...)
+		}
+		return append(dst, f.Value.(error).Error()...)
+	}
+	return append(dst, fmt.Sprint(f.Value)...)
+}
+
+func (f Field) time() time.Time {
+	t := time.Unix(0, f.num)
+	if f.loc != nil {
+		t = t.In(f.loc)
+	}
+	return t
+}
@@ -1,20 +1,26 @@
 package log
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
 
 var (
 	NopLogger = newNopLogger()
-	g         *Logger
+	global    atomic.Value // *Logger
 )
 
 func init() {
-	g = &Logger{
-		sink: &StreamSink{
-			Stream:    os.Stderr,
-			Formatter: DefaultTextFormatterForHuman,
-		},
-		filter: InfoLevelFilter,
-	}
+	global.Store(newLogger(&StreamSink{
+		Stream:    os.Stderr,
+		Formatter: DefaultTextFormatterForHuman,
+	}, &loggerState{
+		filter:     InfoLevelFilter,
+		level:      InfoLevel,
+		leveled:    true,
+		stackLevel: ErrorLevel,
+	}))
 }
 
 func newNopLogger() *Logger {
@@ -24,19 +30,29 @@ func newNopLogger() *Logger {
 }
 
 func New(sink Sink) *Logger {
-	return &Logger{sink, AllLevelsFilter, nil, FlagTime}
+	return newLogger(sink, &loggerState{
+		filter:     AllLevelsFilter,
+		level:      ^Level(0),
+		leveled:    true,
+		flags:      FlagTime,
+		stackLevel: ErrorLevel,
+	})
 }
 
+// SetLogger replaces the global logger. It's safe to call while other
+// goroutines are logging.
 func SetLogger(l *Logger) {
 	if l == nil {
-		g = NopLogger
-	} else {
-		g = l
+		l = NopLogger
 	}
+	global.Store(l)
+	resetNamedLoggers()
 }
 
 func GetLogger() *Logger {
-	return g
+	// Only nil while the package is being initialized.
+	l, _ := global.Load().(*Logger)
+	return l
 }
 
 func GetSink(logger *Logger) Sink {
@@ -44,14 +60,37 @@ func GetSink(logger *Logger) Sink {
 }
 
 func GetFilter(logger *Logger) func(Level) bool {
-	return logger.filter
+	return logger.loadState().filter
 }
 
+// SetFilter sets the filter of the logger. It's safe to call while
+// other goroutines are logging. SetFilterLevel is preferred for
+// filtering by level, since it's cheaper to check.
 func SetFilter(logger *Logger, filter func(Level) bool) {
 	if filter == nil {
 		filter = AllLevelsFilter
 	}
-	logger.filter = filter
+	logger.updateState(func(s *loggerState) {
+		s.filter = filter
+		s.level, s.leveled = 0, false
+	})
+	resetIfGlobal(logger)
+}
+
+// SetFilterLevel sets the filter of the logger to LogFilterForLevel of
+// the level.
+func SetFilterLevel(logger *Logger, lvl Level) {
+	filter := LogFilterForLevel(lvl)
+	if !IsValidLevel(lvl) {
+		// As LogFilterForLevel does.
+		lvl = InfoLevel
+	}
+	logger.updateState(func(s *loggerState) {
+		s.filter = filter
+		// DisabledLevel itself would pass the comparison.
+		s.level, s.leveled = lvl, lvl != DisabledLevel
+	})
+	resetIfGlobal(logger)
 }
 
 func GetFields(logger *Logger) []Field {
@@ -59,99 +98,195 @@ func GetFields(logger *Logger) []Field {
 }
 
 func GetFlags(logger *Logger) loggerFlags {
-	return logger.flags
+	return logger.loadState().flags
 }
 
 func SetFlags(logger *Logger, flags loggerFlags) {
-	logger.flags = flags
+	logger.updateState(func(s *loggerState) {
+		s.flags = flags
+	})
+	resetIfGlobal(logger)
+}
+
+func GetStackLevel(logger *Logger) Level {
+	return logger.loadState().stackLevel
+}
+
+// SetStackLevel sets the least severe level for which the stack is
+// captured, when the logger has FlagStack.
+func SetStackLevel(logger *Logger, lvl Level) {
+	logger.updateState(func(s *loggerState) {
+		s.stackLevel = lvl
+	})
+	resetIfGlobal(logger)
+}
+
+// resetIfGlobal drops the cached named loggers, when the settings they
+// were derived with have changed.
+func resetIfGlobal(logger *Logger) {
+	if logger == GetLogger() {
+		resetNamedLoggers()
+	}
 }
 
 // Log methods
 
 func Log(lvl Level, message string) {
-	logCore(g, lvl, message, nil, skipFramesNum)
+	logCore(GetLogger(), lvl, message, nil, skipFramesNum)
 }
 
 func Error(message string) {
-	logCore(g, ErrorLevel, message, nil, skipFramesNum)
+	logCore(GetLogger(), ErrorLevel, message, nil, skipFramesNum)
 }
 
 func Warn(message string) {
-	logCore(g, WarnLevel, message, nil, skipFramesNum)
+	logCore(GetLogger(), WarnLevel, message, nil, skipFramesNum)
 }
 
 func Info(message string) {
-	logCore(g, InfoLevel, message, nil, skipFramesNum)
+	logCore(GetLogger(), InfoLevel, message, nil, skipFramesNum)
 }
 
 func Debug(message string) {
-	logCore(g, DebugLevel, message, nil, skipFramesNum)
+	logCore(GetLogger(), DebugLevel, message, nil, skipFramesNum)
 }
 
 func Trace(message string) {
-	logCore(g, TraceLevel, message, nil, skipFramesNum)
+	logCore(GetLogger(), TraceLevel, message, nil, skipFramesNum)
 }
 
 // Logv methods
 
 func Logv(lvl Level, args ...interface{}) {
-	logCore(g, lvl, "", args, skipFramesNum)
+	logCore(GetLogger(), lvl, "", args, skipFramesNum)
 }
 
 func Errorv(args ...interface{}) {
-	logCore(g, ErrorLevel, "", args, skipFramesNum)
+	logCore(GetLogger(), ErrorLevel, "", args, skipFramesNum)
 }
 
 func Warnv(args ...interface{}) {
-	logCore(g, WarnLevel, "", args, skipFramesNum)
+	logCore(GetLogger(), WarnLevel, "", args, skipFramesNum)
 }
 
 func Infov(args ...interface{}) {
-	logCore(g, InfoLevel, "", args, skipFramesNum)
+	logCore(GetLogger(), InfoLevel, "", args, skipFramesNum)
 }
 
 func Debugv(args ...interface{}) {
-	logCore(g, DebugLevel, "", args, skipFramesNum)
+	logCore(GetLogger(), DebugLevel, "", args, skipFramesNum)
 }
 
 func Tracev(args ...interface{}) {
-	logCore(g, TraceLevel, "", args, skipFramesNum)
+	logCore(GetLogger(), TraceLevel, "", args, skipFramesNum)
+}
+
+// Logw methods
+
+func Logw(lvl Level, message string, fields ...Field) {
+	logwCore(GetLogger(), lvl, message, fields, skipFramesNum)
+}
+
+func Errorw(message string, fields ...Field) {
+	logwCore(GetLogger(), ErrorLevel, message, fields, skipFramesNum)
+}
+
+func Warnw(message string, fields ...Field) {
+	logwCore(GetLogger(), WarnLevel, message, fields, skipFramesNum)
+}
+
+func Infow(message string, fields ...Field) {
+	logwCore(GetLogger(), InfoLevel, message, fields, skipFramesNum)
+}
+
+func Debugw(message string, fields ...Field) {
+	logwCore(GetLogger(), DebugLevel, message, fields, skipFramesNum)
+}
+
+func Tracew(message string, fields ...Field) {
+	logwCore(GetLogger(), TraceLevel, message, fields, skipFramesNum)
 }
 
 // Logf methods
 
 func Logf(lvl Level, format string, args ...interface{}) {
-	logCore(g, lvl, format, args, skipFramesNum)
+	logCore(GetLogger(), lvl, format, args, skipFramesNum)
 }
 
 func Errorf(format string, args ...interface{}) {
-	logCore(g, ErrorLevel, format, args, skipFramesNum)
+	logCore(GetLogger(), ErrorLevel, format, args, skipFramesNum)
 }
 
 func Warnf(format string, args ...interface{}) {
-	logCore(g, WarnLevel, format, args, skipFramesNum)
+	logCore(GetLogger(), WarnLevel, format, args, skipFramesNum)
 }
 
 func Infof(format string, args ...interface{}) {
-	logCore(g, InfoLevel, format, args, skipFramesNum)
+	logCore(GetLogger(), InfoLevel, format, args, skipFramesNum)
 }
 
 func Debugf(format string, args ...interface{}) {
-	logCore(g, DebugLevel, format, args, skipFramesNum)
+	logCore(GetLogger(), DebugLevel, format, args, skipFramesNum)
 }
 
 func Tracef(format string, args ...interface{}) {
-	logCore(g, TraceLevel, format, args, skipFramesNum)
+	logCore(GetLogger(), TraceLevel, format, args, skipFramesNum)
+}
+
+// Fatal and panic methods
+
+func Fatal(message string) {
+	logCore(GetLogger(), FatalLevel, message, nil, skipFramesNum)
+	exit(GetLogger())
+}
+
+func Fatalv(args ...interface{}) {
+	logCore(GetLogger(), FatalLevel, "", args, skipFramesNum)
+	exit(GetLogger())
+}
+
+func Fatalw(message string, fields ...Field) {
+	logwCore(GetLogger(), FatalLevel, message, fields, skipFramesNum)
+	exit(GetLogger())
+}
+
+func Fatalf(format string, args ...interface{}) {
+	logCore(GetLogger(), FatalLevel, format, args, skipFramesNum)
+	exit(GetLogger())
+}
+
+func Panic(message string) {
+	logCore(GetLogger(), PanicLevel, message, nil, skipFramesNum)
+	GetLogger().Flush()
+	panic(message)
+}
+
+func Panicv(args ...interface{}) {
+	logCore(GetLogger(), PanicLevel, "", args, skipFramesNum)
+	GetLogger().Flush()
+	panic(fmt.Sprint(args...))
+}
+
+func Panicw(message string, fields ...Field) {
+	logwCore(GetLogger(), PanicLevel, message, fields, skipFramesNum)
+	GetLogger().Flush()
+	panic(message)
+}
+
+func Panicf(format string, args ...interface{}) {
+	logCore(GetLogger(), PanicLevel, format, args, skipFramesNum)
+	GetLogger().Flush()
+	panic(fmt.Sprintf(format, args...))
 }
 
 func Flush() {
-	g.Flush()
+	GetLogger().Flush()
 }
 
 func With(name string, val interface{}) *Logger {
-	return g.With(name, val)
+	return GetLogger().With(name, val)
 }
 
 func WithFields(fields []Field) *Logger {
-	return g.WithFields(fields)
+	return GetLogger().WithFields(fields)
 }
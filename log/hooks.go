@@ -0,0 +1,102 @@
+package log
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// Hook is called with each record that's enabled for a logger, before
+// it's passed to the sink. Hooks run in the order they were added, and
+// may append to the fields of the record, but shouldn't keep the record
+// past the call, the same as sinks.
+type Hook interface {
+	Fire(r *Record)
+}
+
+type HookFunc func(r *Record)
+
+func (f HookFunc) Fire(r *Record) {
+	f(r)
+}
+
+// WithHooks returns a logger that runs the hooks, after the ones of
+// this logger.
+func (l *Logger) WithHooks(hooks ...Hook) *Logger {
+	x := l.derive()
+	x.hooks = make([]Hook, 0, len(l.hooks)+len(hooks))
+	x.hooks = append(x.hooks, l.hooks...)
+	x.hooks = append(x.hooks, hooks...)
+	return x
+}
+
+// fireHooks runs the hooks of the logger of the record, before it's
+// passed to the sink.
+func fireHooks(r *Record) {
+	if l := r.Meta.Logger; l != nil {
+		for _, h := range l.hooks {
+			h.Fire(r)
+		}
+	}
+}
+
+// LevelHook calls fn with the records of the level or more severe, like
+// for counting the errors, or alerting on them.
+func LevelHook(lvl Level, fn func(r *Record)) Hook {
+	return HookFunc(func(r *Record) {
+		if r.Meta.Level <= lvl {
+			fn(r)
+		}
+	})
+}
+
+// FieldsHook adds the fields to every record.
+func FieldsHook(fields ...Field) Hook {
+	return HookFunc(func(r *Record) {
+		r.Fields = append(r.Fields, fields...)
+	})
+}
+
+// HostnameHook adds the "host" field, with the hostname as it was when
+// the hook was created.
+func HostnameHook() Hook {
+	host, _ := os.Hostname()
+	return FieldsHook(String("host", host))
+}
+
+// PidHook adds the "pid" field.
+func PidHook() Hook {
+	return FieldsHook(Int("pid", os.Getpid()))
+}
+
+// VersionHook adds the "version" field, like the build version of the
+// app.
+func VersionHook(version string) Hook {
+	return FieldsHook(String("version", version))
+}
+
+// GoroutineIDHook adds the "goroutine" field, with the id of the
+// goroutine that logged the record. It's meant for debugging, since
+// getting the id involves formatting the current stack frame.
+func GoroutineIDHook() Hook {
+	return HookFunc(func(r *Record) {
+		r.Fields = append(r.Fields, Int64("goroutine", goroutineID()))
+	})
+}
+
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	// The stack starts with "goroutine 123 [running]:".
+	const prefix = "goroutine "
+	if len(b) < len(prefix) {
+		return 0
+	}
+	b = b[len(prefix):]
+	i := 0
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+	id, _ := strconv.ParseInt(string(b[:i]), 10, 64)
+	return id
+}
@@ -0,0 +1,232 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/prasannavl/go-gluons/ansicode"
+)
+
+type HumanFormatterOpts struct {
+	Color bool
+	// Theme is the colors, when Color is set. The one set by SetTheme is
+	// used when it's nil.
+	Theme *Theme
+	// PrettyStructs renders the struct, map and slice values of the
+	// fields as indented JSON, instead of on a single line.
+	PrettyStructs bool
+}
+
+func DefaultHumanFormatterOpts() HumanFormatterOpts {
+	return HumanFormatterOpts{}
+}
+
+// NewHumanFormatter returns a formatter like DefaultTextFormatterForHuman,
+// or its colored variant, with the options.
+func NewHumanFormatter(opts *HumanFormatterOpts) func(r *Record) string {
+	if opts == nil {
+		o := DefaultHumanFormatterOpts()
+		opts = &o
+	}
+	o := *opts
+	return func(r *Record) string {
+		return formatHuman(&o, r)
+	}
+}
+
+// maxErrorDepth limits the causes rendered of an error.
+const maxErrorDepth = 10
+
+// formatHuman renders the record on a line, with the continuation lines
+// of the message and the fields, and the causes of the errors, indented
+// to where the message starts.
+func formatHuman(o *HumanFormatterOpts, r *Record) string {
+	var t *Theme
+	if o.Color {
+		if t = o.Theme; t == nil {
+			t = GetTheme()
+		}
+	}
+	var buf bytes.Buffer
+	width := 0
+	f := GetFlags(r.Meta.Logger)
+	if f&FlagTime == FlagTime {
+		timeFormat := "15:04:05"
+		if r.Meta.Time.Sub(initTime).Hours() > 24 {
+			timeFormat = "15:04:05 (Jan 02)"
+		}
+		ts := r.Meta.Time.Format(timeFormat)
+		sep := " "
+		if t != nil {
+			ts = colored(t.Time, ts)
+			sep = "  "
+		}
+		buf.WriteString(ts + sep)
+		width += len(timeFormat) + len(sep)
+	}
+	lvl := r.Meta.Level
+	levelText := PaddedString(LogLevelString(lvl), 5)
+	width += len(levelText) + 2
+	if t != nil {
+		levelText = colored(t.LevelColor(lvl), levelText)
+	}
+	buf.WriteString(levelText + "  ")
+	indent := "\r\n" + strings.Repeat(" ", width)
+
+	// The args are colorized in a copy, since the record is shared with
+	// the other sinks.
+	msgRecord := r
+	if t != nil {
+		var args []interface{}
+		for i, a := range r.Args {
+			if colorable, ok := a.(ColorStringer); ok {
+				if args == nil {
+					args = append([]interface{}(nil), r.Args...)
+				}
+				args[i] = colorable.ColorString()
+			}
+		}
+		if args != nil {
+			c := *r
+			c.Args = args
+			msgRecord = &c
+		}
+	}
+	writeIndented(&buf, FormatMessage(msgRecord), indent)
+
+	var scratch []byte
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			name := x.Name
+			if t != nil {
+				name = colored(t.FieldColor(x.Name), x.Name)
+			}
+			buf.WriteString(" " + name + "=")
+			if colorable, ok := x.Value.(ColorStringer); ok && x.Kind == AnyKind && t != nil {
+				buf.WriteString(colorable.ColorString())
+			} else if err, ok := fieldError(x); ok {
+				scratch = appendErrorTree(scratch[:0], err)
+				writeIndented(&buf, string(scratch), indent)
+			} else if b, ok := prettyValue(o, x); ok {
+				writeIndented(&buf, string(b), indent)
+			} else {
+				scratch = x.AppendText(scratch[:0])
+				writeIndented(&buf, string(scratch), indent)
+			}
+			buf.WriteByte(' ')
+		}
+	}
+	if f&FlagSrcHint == FlagSrcHint {
+		buf.WriteString(" " + r.Meta.File + ":" + strconv.Itoa(r.Meta.Line))
+	}
+	if len(r.Stack) > 0 {
+		if t != nil {
+			buf.WriteString(t.Stack)
+		}
+		writeStackLines(&buf, r.Stack)
+		if t != nil && t.Stack != "" {
+			buf.WriteString(ansicode.Reset)
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.String()
+}
+
+// writeIndented writes the text with its line breaks replaced by the
+// indent, which starts with a line break of its own.
+func writeIndented(buf *bytes.Buffer, text string, indent string) {
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			buf.WriteString(text)
+			return
+		}
+		buf.WriteString(strings.TrimSuffix(text[:i], "\r"))
+		text = text[i+1:]
+		if text == "" {
+			return
+		}
+		buf.WriteString(indent)
+	}
+}
+
+// fieldError returns the error of the field, if it has causes to render.
+func fieldError(x Field) (error, bool) {
+	if x.Kind != ErrorKind && x.Kind != AnyKind {
+		return nil, false
+	}
+	err, ok := x.Value.(error)
+	if !ok || err == nil || errorCause(err) == nil {
+		return nil, false
+	}
+	return err, true
+}
+
+// errorCause returns the error wrapped by err, as go-errors and
+// github.com/pkg/errors expose it with Cause, or as the standard errors
+// do with Unwrap.
+func errorCause(err error) error {
+	switch x := err.(type) {
+	case interface{ Cause() error }:
+		return x.Cause()
+	case interface{ Unwrap() error }:
+		return x.Unwrap()
+	}
+	return nil
+}
+
+// appendErrorTree renders the error, followed by its causes on lines of
+// their own, each nested in the one before. Messages that end with the
+// one of their cause, like those of fmt.Errorf with %w, are trimmed of
+// it, so that it's not repeated.
+func appendErrorTree(dst []byte, err error) []byte {
+	depth := 0
+	for i := 0; err != nil && i < maxErrorDepth; i++ {
+		cause := errorCause(err)
+		msg := err.Error()
+		if cause != nil {
+			causeMsg := cause.Error()
+			if msg == causeMsg {
+				// Wrapped without a message of its own.
+				err = cause
+				continue
+			}
+			msg = strings.TrimSuffix(msg, ": "+causeMsg)
+		}
+		if depth > 0 {
+			dst = append(dst, '\n')
+			dst = append(dst, strings.Repeat("   ", depth-1)+"└─ "...)
+		}
+		dst = append(dst, msg...)
+		err = cause
+		depth++
+	}
+	return dst
+}
+
+// prettyValue returns the value of the field as indented JSON, if it's
+// a struct, map or slice, and pretty structs are enabled. Values with a
+// String method are left to it.
+func prettyValue(o *HumanFormatterOpts, x Field) ([]byte, bool) {
+	if !o.PrettyStructs || x.Kind != AnyKind || x.Value == nil {
+		return nil, false
+	}
+	if _, ok := x.Value.(fmt.Stringer); ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(x.Value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+	b, err := json.MarshalIndent(x.Value, "", "  ")
+	return b, err == nil
+}
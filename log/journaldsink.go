@@ -0,0 +1,154 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type JournaldSinkOpts struct {
+	Address    string
+	Identifier string
+	// Formatter renders the MESSAGE field. Defaults to FormatMessage.
+	Formatter func(*Record) string
+}
+
+func DefaultJournaldSinkOpts() JournaldSinkOpts {
+	return JournaldSinkOpts{
+		Address:    "/run/systemd/journal/socket",
+		Identifier: filepath.Base(os.Args[0]),
+		Formatter:  FormatMessage,
+	}
+}
+
+// JournaldSink writes records to the systemd journal using its native
+// protocol, with the fields of the record as journal fields. Field names
+// are upper-cased, and characters that journald doesn't allow are
+// replaced with underscores. Names that would clash with the fields of
+// the journal itself, like MESSAGE or PRIORITY, are prefixed with "F_".
+// Records too large for a datagram are passed in a file.
+type JournaldSink struct {
+	opts JournaldSinkOpts
+	m    sync.Mutex
+	conn *net.UnixConn
+	addr *net.UnixAddr
+	buf  bytes.Buffer
+}
+
+func NewJournaldSink(opts *JournaldSinkOpts) (*JournaldSink, error) {
+	if opts == nil {
+		o := DefaultJournaldSinkOpts()
+		opts = &o
+	}
+	s := &JournaldSink{opts: *opts}
+	if s.opts.Formatter == nil {
+		s.opts.Formatter = FormatMessage
+	}
+	s.addr = &net.UnixAddr{Name: s.opts.Address, Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	// Fail early, when journald isn't available.
+	if _, err = os.Stat(s.opts.Address); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func (s *JournaldSink) Log(r *Record) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	buf := &s.buf
+	buf.Reset()
+	writeJournalField(buf, "MESSAGE", s.opts.Formatter(r))
+	writeJournalField(buf, "PRIORITY", strconv.Itoa(SyslogSeverity(r.Meta.Level)))
+	if s.opts.Identifier != "" {
+		writeJournalField(buf, "SYSLOG_IDENTIFIER", s.opts.Identifier)
+	}
+	if r.Meta.File != "" {
+		writeJournalField(buf, "CODE_FILE", r.Meta.File)
+		writeJournalField(buf, "CODE_LINE", strconv.Itoa(r.Meta.Line))
+	}
+	var scratch []byte
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			scratch = x.AppendText(scratch[:0])
+			writeJournalField(buf, journalFieldName(x.Name), string(scratch))
+		}
+	}
+	if _, err := s.conn.WriteToUnix(buf.Bytes(), s.addr); err != nil && isMsgTooLarge(err) {
+		s.writeFile(buf.Bytes())
+	}
+}
+
+func (s *JournaldSink) Flush() {}
+
+func (s *JournaldSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.conn.Close()
+}
+
+func writeJournalField(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+	} else {
+		// Values with newlines are sent with their size instead,
+		// as a little-endian uint64.
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		buf.WriteByte('\n')
+		buf.Write(size[:])
+		buf.WriteString(value)
+	}
+	buf.WriteByte('\n')
+}
+
+// reservedJournalFields are the fields with a meaning to the journal,
+// that the fields of a record aren't allowed to set.
+var reservedJournalFields = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"ERRNO":              true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+}
+
+// Journal field names may only contain upper-case letters, digits and
+// underscores, and cannot begin with an underscore or a digit.
+func journalFieldName(name string) string {
+	b := []byte(strings.ToUpper(name))
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] == '_' || b[0] >= '0' && b[0] <= '9' || reservedJournalFields[string(b)] {
+		b = append([]byte("F_"), b...)
+	}
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	return string(b)
+}
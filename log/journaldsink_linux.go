@@ -0,0 +1,32 @@
+//go:build linux
+// +build linux
+
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+func isMsgTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// writeFile sends a message too large for a datagram the way journald
+// accepts it, as an unlinked file in /dev/shm passed over the socket.
+// It's what libsystemd falls back to without memfds.
+func (s *JournaldSink) writeFile(b []byte) error {
+	f, err := ioutil.TempFile("/dev/shm", "journal-message")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	_, _, err = s.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), s.addr)
+	return err
+}
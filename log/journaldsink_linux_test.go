@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+func TestJournaldSinkFile(t *testing.T) {
+	conn, addr, cleanup := listenUnixgram(t)
+	defer cleanup()
+	s, err := log.NewJournaldSink(&log.JournaldSinkOpts{Address: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := log.New(s)
+	msg := strings.Repeat("x", 4<<20)
+	l.Info(msg)
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected a file descriptor, got %v, %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected a file descriptor, got %v, %v", fds, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal-message")
+	defer f.Close()
+	// The offset is shared with the sender, that left it at the end.
+	if _, err = f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "MESSAGE="+msg+"\nPRIORITY=6\n") {
+		t.Errorf("unexpected message of %d bytes", len(b))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package log
+
+import "errors"
+
+func isMsgTooLarge(err error) bool {
+	return false
+}
+
+func (s *JournaldSink) writeFile(b []byte) error {
+	return errors.New("log: passing files to journald is only supported on linux")
+}
@@ -0,0 +1,163 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONFormatter renders each record as a single JSON object per line.
+// Field values keep their JSON types wherever possible, instead of being
+// stringified.
+func JSONFormatter(r *Record) string {
+	var buf bytes.Buffer
+	f := GetFlags(r.Meta.Logger)
+	buf.WriteByte('{')
+	if f&FlagTime == FlagTime {
+		buf.WriteString(`"time":`)
+		writeJSONString(&buf, r.Meta.Time.Format(time.RFC3339Nano))
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"level":`)
+	writeJSONString(&buf, LogLevelString(r.Meta.Level))
+	buf.WriteString(`,"msg":`)
+	args := r.Args
+	if r.Format == "" {
+		writeJSONString(&buf, fmt.Sprint(args...))
+	} else if len(args) > 0 {
+		writeJSONString(&buf, fmt.Sprintf(r.Format, args...))
+	} else {
+		writeJSONString(&buf, r.Format)
+	}
+	if f&FlagSrcHint == FlagSrcHint {
+		buf.WriteString(`,"file":`)
+		writeJSONString(&buf, r.Meta.File)
+		buf.WriteString(`,"line":`)
+		buf.WriteString(strconv.Itoa(r.Meta.Line))
+		if r.Meta.Function != "" {
+			buf.WriteString(`,"func":`)
+			writeJSONString(&buf, r.Meta.Function)
+		}
+	}
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			buf.WriteByte(',')
+			writeJSONString(&buf, x.Name)
+			buf.WriteByte(':')
+			writeJSONField(&buf, x)
+		}
+	}
+	if len(r.Stack) > 0 {
+		buf.WriteString(`,"stack":[`)
+		for i, fr := range r.Stack {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(`{"func":`)
+			writeJSONString(&buf, fr.Function)
+			buf.WriteString(`,"file":`)
+			writeJSONString(&buf, fr.File)
+			buf.WriteString(`,"line":`)
+			buf.WriteString(strconv.Itoa(fr.Line))
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("}\r\n")
+	return buf.String()
+}
+
+func writeJSONField(buf *bytes.Buffer, f Field) {
+	switch f.Kind {
+	case StringKind:
+		writeJSONString(buf, f.str)
+	case DurationKind, TimeKind:
+		var scratch [64]byte
+		writeJSONString(buf, string(f.AppendText(scratch[:0])))
+	case IntKind, BoolKind:
+		var scratch [32]byte
+		buf.Write(f.AppendText(scratch[:0]))
+	default:
+		writeJSONValue(buf, f.Value)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch b {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(b)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[b>>4])
+				buf.WriteByte(hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
+
+func writeJSONValue(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteString("null")
+		return
+	case string:
+		writeJSONString(buf, x)
+		return
+	case json.Marshaler:
+		// Takes precedence, so that types like time.Time or
+		// errors that know how to marshal themselves are honored.
+	case error:
+		writeJSONString(buf, x.Error())
+		return
+	case time.Duration:
+		writeJSONString(buf, x.String())
+		return
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		// Values that cannot be represented in JSON (channels, funcs,
+		// cyclic structures or a failing marshaler) are stringified
+		// so that the line itself always remains valid.
+		b.Reset()
+		enc.Encode(fmt.Sprint(v))
+	}
+	// Encode always terminates with a newline
+	buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+}
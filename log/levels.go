@@ -0,0 +1,89 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type customLevel struct {
+	name  string
+	color string
+}
+
+var customLevels = struct {
+	m       sync.RWMutex
+	byLevel map[Level]customLevel
+	byName  map[string]Level
+}{
+	byLevel: make(map[Level]customLevel),
+	byName:  make(map[string]Level),
+}
+
+// RegisterLevel adds a level of the given name, that's rendered with the
+// color by the colored formatters, or with the trace color of the theme
+// if it's empty. A level is as severe as its value places it among the
+// others, so for instance, a "notice" level in between WarnLevel and
+// InfoLevel can be any of the values from 9 to 15. Filters for a level
+// let through the records of all the levels up to it.
+func RegisterLevel(lvl Level, name string, color string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("log: level %d has no name", lvl)
+	}
+	if isBuiltinLevel(lvl) {
+		return fmt.Errorf("log: level %d is already %q", lvl, LogLevelString(lvl))
+	}
+	if x := LogLevelFromString(name); IsValidLevel(x) {
+		return fmt.Errorf("log: level name %q is already taken", name)
+	}
+	customLevels.m.Lock()
+	defer customLevels.m.Unlock()
+	if x, ok := customLevels.byLevel[lvl]; ok {
+		return fmt.Errorf("log: level %d is already %q", lvl, x.name)
+	}
+	customLevels.byLevel[lvl] = customLevel{name, color}
+	customLevels.byName[name] = lvl
+	return nil
+}
+
+func unregisterLevel(lvl Level) {
+	customLevels.m.Lock()
+	defer customLevels.m.Unlock()
+	if x, ok := customLevels.byLevel[lvl]; ok {
+		delete(customLevels.byName, x.name)
+		delete(customLevels.byLevel, lvl)
+	}
+}
+
+func lookupCustomLevel(lvl Level) (customLevel, bool) {
+	customLevels.m.RLock()
+	x, ok := customLevels.byLevel[lvl]
+	customLevels.m.RUnlock()
+	return x, ok
+}
+
+func lookupCustomLevelName(name string) (Level, bool) {
+	customLevels.m.RLock()
+	lvl, ok := customLevels.byName[name]
+	customLevels.m.RUnlock()
+	return lvl, ok
+}
+
+func isBuiltinLevel(lvl Level) bool {
+	switch lvl {
+	case DisabledLevel, FatalLevel, PanicLevel, ErrorLevel, WarnLevel, InfoLevel, DebugLevel, TraceLevel:
+		return true
+	}
+	return false
+}
+
+// ParseLevel is like LogLevelFromString, except that it's case
+// insensitive, and that unknown names are an error.
+func ParseLevel(level string) (Level, error) {
+	lvl := LogLevelFromString(strings.ToLower(strings.TrimSpace(level)))
+	if !IsValidLevel(lvl) {
+		return lvl, fmt.Errorf("log: unknown level %q", level)
+	}
+	return lvl, nil
+}
@@ -1,16 +1,23 @@
 package log
 
 import (
+	"fmt"
+	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Level uint
 
-// Power of 2
+// Power of 2. The gaps in between are left for custom levels, see
+// RegisterLevel.
 const (
 	DisabledLevel Level = 0
-	ErrorLevel          = 1 << (iota - 1)
+	FatalLevel          = 1 << (iota - 1)
+	PanicLevel
+	ErrorLevel
 	WarnLevel
 	InfoLevel
 	DebugLevel
@@ -23,24 +30,80 @@ const (
 	_           loggerFlags = 0
 	FlagTime                = 1 << (iota - 1)
 	FlagSrcHint
+	// FlagStack captures the stack for records at or above the stack
+	// level of the logger (ErrorLevel by default). See SetStackLevel.
+	FlagStack
 )
 
 type Logger struct {
-	sink   Sink
+	sink       Sink
+	state      atomic.Value // *loggerState
+	fields     []Field
+	callerSkip int
+	name       string
+	node       *levelNode
+	hooks      []Hook
+}
+
+// loggerState holds what can be changed on a logger once it's created.
+// It's never modified, but replaced as a whole, so that it can be read
+// without locks while it's being changed.
+type loggerState struct {
 	filter func(Level) bool
-	fields []Field
-	flags  loggerFlags
+	// level is the least severe level the filter allows, when it's set
+	// by SetFilterLevel, so that the filter needn't be called.
+	level      Level
+	leveled    bool
+	flags      loggerFlags
+	stackLevel Level
+}
+
+// stateMu serializes the changes to the state of the loggers, so that
+// concurrent changes of different settings aren't lost.
+var stateMu sync.Mutex
+
+func newLogger(sink Sink, state *loggerState) *Logger {
+	l := &Logger{sink: sink}
+	l.state.Store(state)
+	return l
 }
 
+func (l *Logger) loadState() *loggerState {
+	return l.state.Load().(*loggerState)
+}
+
+func (l *Logger) updateState(update func(s *loggerState)) {
+	stateMu.Lock()
+	s := *l.loadState()
+	update(&s)
+	l.state.Store(&s)
+	stateMu.Unlock()
+}
+
+// derive returns a copy of the logger, with its current state.
+func (l *Logger) derive() *Logger {
+	x := &Logger{
+		sink:       l.sink,
+		fields:     l.fields,
+		callerSkip: l.callerSkip,
+		name:       l.name,
+		node:       l.node,
+		hooks:      l.hooks,
+	}
+	x.state.Store(l.loadState())
+	return x
+}
+
+// Record is only valid for the duration of the Sink.Log call. Records are
+// reused, so sinks that hold on to one past that have to copy it along
+// with its Fields.
 type Record struct {
 	Meta   Metadata
 	Format string
 	Args   []interface{}
-}
-
-type Field struct {
-	Name  string
-	Value interface{}
+	Fields []Field
+	// Stack is only set when the logger has FlagStack.
+	Stack []Frame
 }
 
 type Metadata struct {
@@ -49,36 +112,127 @@ type Metadata struct {
 	Time   time.Time
 	File   string
 	Line   int
+	// Function is the qualified name of the calling function, like
+	// "github.com/prasannavl/go-gluons/log.TestPrint".
+	Function string
+	// PC is the program counter of the call, set along with the file
+	// and line.
+	PC uintptr
 }
 
 const skipFramesNum = 3
 
+var recordPool = sync.Pool{
+	New: func() interface{} { return &Record{} },
+}
+
 func logCore(l *Logger, lvl Level, format string, args []interface{}, skipStackFramesNum int) {
 	if l.IsEnabled(lvl) {
-		r := Record{
-			Meta:   newMetadata(l, lvl, skipStackFramesNum),
-			Format: format,
-			Args:   args,
+		r := recordPool.Get().(*Record)
+		r.Meta = newMetadata(l, lvl, skipStackFramesNum)
+		r.Format = format
+		r.Args = args
+		if l.loadState().wantsStack(lvl) {
+			r.Stack = appendStack(r.Stack[:0], skipStackFramesNum-1+l.callerSkip)
 		}
-		l.sink.Log(&r)
+		fireHooks(r)
+		l.sink.Log(r)
+		releaseRecord(r)
 	}
 }
 
+func logwCore(l *Logger, lvl Level, message string, fields []Field, skipStackFramesNum int) {
+	if l.IsEnabled(lvl) {
+		r := recordPool.Get().(*Record)
+		r.Meta = newMetadata(l, lvl, skipStackFramesNum)
+		r.Format = message
+		// Copied into the pooled slice, so that the variadic
+		// fields of the caller never escape to the heap.
+		r.Fields = append(r.Fields[:0], fields...)
+		if l.loadState().wantsStack(lvl) {
+			r.Stack = appendStack(r.Stack[:0], skipStackFramesNum-1+l.callerSkip)
+		}
+		fireHooks(r)
+		l.sink.Log(r)
+		releaseRecord(r)
+	}
+}
+
+func releaseRecord(r *Record) {
+	for i := range r.Fields {
+		r.Fields[i] = Field{}
+	}
+	*r = Record{Fields: r.Fields[:0], Stack: r.Stack[:0]}
+	recordPool.Put(r)
+}
+
+func (s *loggerState) wantsStack(lvl Level) bool {
+	return s.flags&FlagStack == FlagStack && lvl <= s.stackLevel
+}
+
 func newMetadata(l *Logger, lvl Level, skip int) Metadata {
 	m := Metadata{Logger: l, Level: lvl}
-	f := l.flags
+	f := l.loadState().flags
 	if f&FlagTime == FlagTime {
 		m.Time = time.Now()
 	}
 	if f&FlagSrcHint == FlagSrcHint {
-		if _, file, line, ok := runtime.Caller(skip); ok {
-			m.File = file
-			m.Line = line
+		// Resolved through the frames, rather than FuncForPC, so that
+		// inlined callers get their own function.
+		var pcs [1]uintptr
+		if runtime.Callers(skip+1+l.callerSkip, pcs[:]) > 0 {
+			m.setCaller(pcs[0])
 		}
 	}
 	return m
 }
 
+func (m *Metadata) setCaller(pc uintptr) {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	m.File = frame.File
+	m.Line = frame.Line
+	m.Function = frame.Function
+	m.PC = pc
+}
+
+// LogwFrom is like Logw, for the records that are made elsewhere, like
+// by other logging packages, that pass on their own time and call site.
+// The time is used when the logger has FlagTime, or the current time if
+// it's zero. The pc, when it's not zero, is the call site of the source
+// hint, and where the stack starts, rather than the caller of LogwFrom.
+func (l *Logger) LogwFrom(lvl Level, t time.Time, pc uintptr, message string, fields ...Field) {
+	if !l.IsEnabled(lvl) {
+		return
+	}
+	s := l.loadState()
+	r := recordPool.Get().(*Record)
+	r.Meta = Metadata{Logger: l, Level: lvl}
+	if s.flags&FlagTime == FlagTime {
+		if t.IsZero() {
+			t = time.Now()
+		}
+		r.Meta.Time = t
+	}
+	if pc == 0 && (s.flags&FlagSrcHint == FlagSrcHint || s.wantsStack(lvl)) {
+		// Without a call site, it's the caller, as with Logw.
+		var pcs [1]uintptr
+		if runtime.Callers(2+l.callerSkip, pcs[:]) > 0 {
+			pc = pcs[0]
+		}
+	}
+	if pc != 0 && s.flags&FlagSrcHint == FlagSrcHint {
+		r.Meta.setCaller(pc)
+	}
+	r.Format = message
+	r.Fields = append(r.Fields[:0], fields...)
+	if s.wantsStack(lvl) {
+		r.Stack = appendStackFrom(r.Stack[:0], 1, pc)
+	}
+	fireHooks(r)
+	l.sink.Log(r)
+	releaseRecord(r)
+}
+
 // Log methods
 
 func (l *Logger) Log(lvl Level, message string) {
@@ -131,6 +285,32 @@ func (l *Logger) Tracev(args ...interface{}) {
 	logCore(l, TraceLevel, "", args, skipFramesNum)
 }
 
+// Logw methods
+
+func (l *Logger) Logw(lvl Level, message string, fields ...Field) {
+	logwCore(l, lvl, message, fields, skipFramesNum)
+}
+
+func (l *Logger) Errorw(message string, fields ...Field) {
+	logwCore(l, ErrorLevel, message, fields, skipFramesNum)
+}
+
+func (l *Logger) Warnw(message string, fields ...Field) {
+	logwCore(l, WarnLevel, message, fields, skipFramesNum)
+}
+
+func (l *Logger) Infow(message string, fields ...Field) {
+	logwCore(l, InfoLevel, message, fields, skipFramesNum)
+}
+
+func (l *Logger) Debugw(message string, fields ...Field) {
+	logwCore(l, DebugLevel, message, fields, skipFramesNum)
+}
+
+func (l *Logger) Tracew(message string, fields ...Field) {
+	logwCore(l, TraceLevel, message, fields, skipFramesNum)
+}
+
 // Logf methods
 
 func (l *Logger) Logf(lvl Level, format string, args ...interface{}) {
@@ -157,8 +337,70 @@ func (l *Logger) Tracef(format string, args ...interface{}) {
 	logCore(l, TraceLevel, format, args, skipFramesNum)
 }
 
+// Fatal methods log the record and exit the process with status 1, after
+// flushing the logger. Panic methods log the record, flush the logger and
+// panic with the message.
+
+func (l *Logger) Fatal(message string) {
+	logCore(l, FatalLevel, message, nil, skipFramesNum)
+	exit(l)
+}
+
+func (l *Logger) Fatalv(args ...interface{}) {
+	logCore(l, FatalLevel, "", args, skipFramesNum)
+	exit(l)
+}
+
+func (l *Logger) Fatalw(message string, fields ...Field) {
+	logwCore(l, FatalLevel, message, fields, skipFramesNum)
+	exit(l)
+}
+
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	logCore(l, FatalLevel, format, args, skipFramesNum)
+	exit(l)
+}
+
+func (l *Logger) Panic(message string) {
+	logCore(l, PanicLevel, message, nil, skipFramesNum)
+	l.Flush()
+	panic(message)
+}
+
+func (l *Logger) Panicv(args ...interface{}) {
+	logCore(l, PanicLevel, "", args, skipFramesNum)
+	l.Flush()
+	panic(fmt.Sprint(args...))
+}
+
+func (l *Logger) Panicw(message string, fields ...Field) {
+	logwCore(l, PanicLevel, message, fields, skipFramesNum)
+	l.Flush()
+	panic(message)
+}
+
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	logCore(l, PanicLevel, format, args, skipFramesNum)
+	l.Flush()
+	panic(fmt.Sprintf(format, args...))
+}
+
+func exit(l *Logger) {
+	l.Flush()
+	os.Exit(1)
+}
+
 func (l *Logger) IsEnabled(lvl Level) bool {
-	return l.filter(lvl)
+	if l.node != nil {
+		if max, ok := l.node.load(); ok {
+			return lvl <= max
+		}
+	}
+	s := l.loadState()
+	if s.leveled {
+		return lvl <= s.level
+	}
+	return s.filter(lvl)
 }
 
 func (l *Logger) Flush() {
@@ -168,13 +410,26 @@ func (l *Logger) Flush() {
 func (l *Logger) With(name string, value interface{}) *Logger {
 	s := make([]Field, 0, len(l.fields)+1)
 	s = append(s, l.fields...)
-	s = append(s, Field{name, value})
-	return &Logger{l.sink, l.filter, s, l.flags}
+	s = append(s, Field{Name: name, Value: value})
+	x := l.derive()
+	x.fields = s
+	return x
 }
 
 func (l *Logger) WithFields(fields []Field) *Logger {
 	s := make([]Field, 0, len(l.fields)+len(fields))
 	s = append(s, l.fields...)
 	s = append(s, fields...)
-	return &Logger{l.sink, l.filter, s, l.flags}
+	x := l.derive()
+	x.fields = s
+	return x
+}
+
+// WithCallerSkip returns a logger that reports the caller skip frames
+// further up the stack. It's meant for logging helpers, so that their
+// records point to the code that called them, rather than themselves.
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	x := l.derive()
+	x.callerSkip += skip
+	return x
 }
@@ -1,10 +1,29 @@
 package log_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prasannavl/go-gluons/cert"
 	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/go-gluons/log/logtest"
 )
 
 func TestPrint(t *testing.T) {
@@ -43,3 +62,1474 @@ func TestPrint(t *testing.T) {
 	l2.With("ctx2", "another val").Info("Hey you")
 	l2.Infof("%s %v", "hello there", "again")
 }
+
+type jsonTestPoint struct {
+	X, Y int
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{
+		Formatter: log.JSONFormatter,
+		Stream:    &buf,
+	})
+	log.SetFlags(l, log.FlagTime|log.FlagSrcHint)
+	l.WithFields([]log.Field{
+		{Name: "err", Value: errors.New("failed <here>")},
+		{Name: "elapsed", Value: 1500 * time.Millisecond},
+		{Name: "at", Value: time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Name: "raw", Value: []byte("hi")},
+		{Name: "point", Value: jsonTestPoint{1, 2}},
+		{Name: "count", Value: 3},
+		{Name: "nothing", Value: nil},
+	}).Infof("hello %s", "world")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"level":   "info",
+		"msg":     "hello world",
+		"err":     "failed <here>",
+		"elapsed": "1.5s",
+		"at":      "2017-01-02T03:04:05Z",
+		"raw":     "aGk=",
+		"count":   float64(3),
+		"nothing": nil,
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("%s: expected %#v, got %#v", k, v, m[k])
+		}
+	}
+	if p, ok := m["point"].(map[string]interface{}); !ok || p["X"] != float64(1) || p["Y"] != float64(2) {
+		t.Errorf("point: unexpected %#v", m["point"])
+	}
+	if _, ok := m["time"].(string); !ok {
+		t.Errorf("time: missing")
+	}
+	if _, ok := m["line"].(float64); !ok {
+		t.Errorf("line: missing")
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{
+		Formatter: log.LogfmtFormatter,
+		Stream:    &buf,
+	})
+	log.SetFlags(l, 0)
+	l.WithFields([]log.Field{
+		{Name: "plain", Value: "value"},
+		{Name: "spaced", Value: "a b"},
+		{Name: "multi", Value: "line1\nline2\tend"},
+		{Name: "quoted", Value: `say "hi"`},
+		{Name: "eq", Value: "a=b"},
+		{Name: "empty", Value: ""},
+		{Name: "bad key", Value: 1},
+	}).Info("hello world")
+
+	expected := `level=info msg="hello world" plain=value spaced="a b" ` +
+		`multi="line1\nline2\tend" quoted="say \"hi\"" eq="a=b" empty="" bad_key=1` + "\r\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+// gateSink holds up the caller of the first record until it's released,
+// like the worker of an async sink, and keeps the messages.
+type gateSink struct {
+	started chan struct{}
+	release chan struct{}
+	m       sync.Mutex
+	msgs    []string
+}
+
+func newGateSink() *gateSink {
+	return &gateSink{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (s *gateSink) Log(r *log.Record) {
+	s.m.Lock()
+	first := len(s.msgs) == 0
+	s.msgs = append(s.msgs, log.FormatMessage(r))
+	s.m.Unlock()
+	if first {
+		close(s.started)
+		<-s.release
+	}
+}
+
+func (s *gateSink) Flush() {}
+
+func (s *gateSink) messages() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+func TestAsyncSinkDropNewest(t *testing.T) {
+	inner := newGateSink()
+	s := log.NewAsyncSink(inner, &log.AsyncSinkOpts{
+		QueueSize: 2,
+		Policy:    log.OverflowDropNewest,
+	})
+	l := log.New(s)
+	// One record may be held by the worker, two queued, and the rest dropped.
+	for i := 0; i < 10; i++ {
+		l.Info("message")
+	}
+	close(inner.release)
+	s.Flush()
+	n := len(inner.messages())
+	if int(s.Dropped())+n != 10 {
+		t.Errorf("expected 10 records accounted for, got %d written and %d dropped", n, s.Dropped())
+	}
+	if n < 2 || n > 3 {
+		t.Errorf("expected 2 or 3 records written, got %d", n)
+	}
+	s.Close()
+	l.Info("after close")
+	if int(s.Dropped())+len(inner.messages()) != 11 {
+		t.Errorf("expected records after close to be dropped")
+	}
+}
+
+func TestAsyncSinkBlock(t *testing.T) {
+	inner := newGateSink()
+	s := log.NewAsyncSink(inner, &log.AsyncSinkOpts{QueueSize: 2, Policy: log.OverflowBlock})
+	l := log.New(s)
+	l.Info("0")
+	<-inner.started
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 4; i++ {
+			l.Infof("%d", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected logging to block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(inner.release)
+	<-done
+	s.Flush()
+	if got := strings.Join(inner.messages(), ","); got != "0,1,2,3,4" || s.Dropped() != 0 {
+		t.Errorf("unexpected records %q, with %d dropped", got, s.Dropped())
+	}
+	s.Close()
+}
+
+func TestAsyncSinkDropOldest(t *testing.T) {
+	inner := newGateSink()
+	s := log.NewAsyncSink(inner, &log.AsyncSinkOpts{QueueSize: 2, Policy: log.OverflowDropOldest})
+	l := log.New(s)
+	l.Info("0")
+	<-inner.started
+	for i := 1; i <= 9; i++ {
+		l.Infof("%d", i)
+	}
+	close(inner.release)
+	s.Flush()
+	if got := strings.Join(inner.messages(), ","); got != "0,8,9" || s.Dropped() != 7 {
+		t.Errorf("unexpected records %q, with %d dropped", got, s.Dropped())
+	}
+	s.Close()
+}
+
+func TestAsyncSinkDropBelowLevel(t *testing.T) {
+	inner := newGateSink()
+	s := log.NewAsyncSink(inner, &log.AsyncSinkOpts{
+		QueueSize:     2,
+		Policy:        log.OverflowDropBelowLevel,
+		PreserveLevel: log.WarnLevel,
+	})
+	l := log.New(s)
+	l.Info("0")
+	<-inner.started
+	l.Info("a")
+	l.Info("b")
+	l.Info("dropped")
+	done := make(chan struct{})
+	go func() {
+		l.Warn("w")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected warnings to block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(inner.release)
+	<-done
+	s.Flush()
+	if got := strings.Join(inner.messages(), ","); got != "0,a,b,w" || s.Dropped() != 1 {
+		t.Errorf("unexpected records %q, with %d dropped", got, s.Dropped())
+	}
+	s.Close()
+}
+
+func TestAsyncSinkFlushUnderLoad(t *testing.T) {
+	s := log.NewAsyncSink(log.NopSink{}, &log.AsyncSinkOpts{QueueSize: 4, Policy: log.OverflowBlock})
+	l := log.New(s)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					l.Info("busy")
+				}
+			}
+		}()
+	}
+	flushed := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			s.Flush()
+		}
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Error("expected Flush to return under steady logging")
+	}
+	close(stop)
+	wg.Wait()
+	s.Close()
+}
+
+func TestInfowAllocs(t *testing.T) {
+	l := log.New(log.NopSink{})
+	err := errors.New("failure")
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Infow("request",
+			log.String("method", "GET"),
+			log.Int("status", 200),
+			log.Int64("size", 1024),
+			log.Bool("cached", true),
+			log.Duration("elapsed", time.Millisecond),
+			log.Time("at", time.Now()),
+			log.Err(err))
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestTypedFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{
+		Formatter: log.DefaultTextFormatter,
+		Stream:    &buf,
+	})
+	log.SetFlags(l, 0)
+	l.With("ctx", "x").Infow("hello",
+		log.String("s", "str"),
+		log.Int("i", -5),
+		log.Bool("b", true),
+		log.Duration("d", 2*time.Second),
+		log.Time("t", time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)),
+		log.Err(nil),
+		log.Any("a", []int{1, 2}))
+	expected := "info\thello\tctx=x\ts=str\ti=-5\tb=true\td=2s\tt=2017-01-02T03:04:05Z\terror=nil\ta=[1 2]\r\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	loc := time.FixedZone("X", 3600)
+	at := time.Date(2017, 1, 2, 3, 4, 5, 0, loc)
+	f := log.Time("t", at)
+	if f.Value != nil {
+		t.Errorf("expected a nil Value for a time field, got %v", f.Value)
+	}
+	if v, ok := f.Interface().(time.Time); !ok || !v.Equal(at) || v.Location() != loc {
+		t.Errorf("expected %v, got %v", at, f.Interface())
+	}
+}
+
+func BenchmarkInfowDisabled(b *testing.B) {
+	l := log.New(log.NopSink{})
+	log.SetFilter(l, log.ErrorLevelFilter)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request", log.String("method", "GET"), log.Int("status", 200))
+	}
+}
+
+func BenchmarkInfow(b *testing.B) {
+	l := log.New(log.NopSink{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request", log.String("method", "GET"), log.Int("status", 200))
+	}
+}
+
+func BenchmarkInfowManyFields(b *testing.B) {
+	l := log.New(log.NopSink{})
+	err := errors.New("failure")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request",
+			log.String("method", "GET"),
+			log.Int("status", 200),
+			log.Int64("size", 1024),
+			log.Bool("cached", true),
+			log.Duration("elapsed", time.Millisecond),
+			log.Time("at", time.Now()),
+			log.Err(err))
+	}
+}
+
+func BenchmarkInfowWithSrcHint(b *testing.B) {
+	l := log.New(log.NopSink{})
+	log.SetFlags(l, log.FlagTime|log.FlagSrcHint)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request", log.String("method", "GET"), log.Int("status", 200))
+	}
+}
+
+func BenchmarkInfof(b *testing.B) {
+	l := log.New(log.NopSink{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infof("request %s %d", "GET", 200)
+	}
+}
+
+func BenchmarkWith(b *testing.B) {
+	l := log.New(log.NopSink{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.With("method", "GET").Info("request")
+	}
+}
+
+func BenchmarkInfowTextFormatter(b *testing.B) {
+	l := log.New(&log.StreamSink{
+		Formatter: log.DefaultTextFormatter,
+		Stream:    ioutil.Discard,
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request", log.String("method", "GET"), log.Int("status", 200))
+	}
+}
+
+func BenchmarkInfowJSONFormatter(b *testing.B) {
+	l := log.New(&log.StreamSink{
+		Formatter: log.JSONFormatter,
+		Stream:    ioutil.Discard,
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request", log.String("method", "GET"), log.Int("status", 200))
+	}
+}
+
+// entryStrings returns the captured entries as strings, oldest first.
+func entryStrings(s *logtest.CapturingSink) []string {
+	var res []string
+	for _, e := range s.Entries() {
+		res = append(res, e.String())
+	}
+	return res
+}
+
+// waitEntries waits for the sink to capture n entries, for the ones that
+// are logged in the background.
+func waitEntries(t *testing.T, s *logtest.CapturingSink, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Entries()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d entries, got %q", n, entryStrings(s))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return entryStrings(s)
+}
+
+// assertEntries compares the captured entries with the expected ones. The
+// expected ones that end with "..." are only prefixes.
+func assertEntries(t *testing.T, got []string, expected []string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	for i, x := range expected {
+		if got[i] != x && !(strings.HasSuffix(x, "...") && strings.HasPrefix(got[i], strings.TrimSuffix(x, "..."))) {
+			t.Errorf("expected %q, got %q", x, got[i])
+		}
+	}
+}
+
+func TestSamplingSink(t *testing.T) {
+	inner := logtest.NewCapturingSink()
+	s := log.NewSamplingSink(inner, &log.SamplingSinkOpts{
+		Interval:   time.Hour,
+		First:      2,
+		Thereafter: 3,
+	})
+	l := log.New(s)
+	log.SetFlags(l, 0)
+	for i := 0; i < 10; i++ {
+		l.Warnf("failed %d", i)
+	}
+	l.Warn("other")
+	s.Flush()
+	assertEntries(t, entryStrings(inner), []string{
+		`warn "failed 0"`,
+		`warn "failed 1"`,
+		`warn "failed 4"`,
+		`warn "failed 7"`,
+		`warn "other"`,
+		`warn "sampling: suppressed 6 occurrences of \"failed %d\""`,
+	})
+}
+
+func TestNamedLevels(t *testing.T) {
+	inner := logtest.NewCapturingSink()
+	l := log.New(inner)
+	log.SetFlags(l, 0)
+	log.SetFilter(l, log.InfoLevelFilter)
+	router := l.Named("test").Named("hostrouter")
+	files := l.Named("test.http.fileserver")
+	if log.GetName(router) != "test.hostrouter" {
+		t.Errorf("unexpected name %q", log.GetName(router))
+	}
+
+	log.SetLevel("test.hostrouter", log.TraceLevel)
+	log.SetLevel("test", log.ErrorLevel)
+	defer log.ResetLevel("test")
+	defer log.ResetLevel("test.hostrouter")
+
+	router.Trace("router trace")
+	files.Warn("files warn")
+	files.With("k", "v").Error("files error")
+	l.Info("root info")
+
+	log.ResetLevel("test.hostrouter")
+	router.Trace("router trace again")
+	log.ResetLevel("test")
+	files.Warn("files warn again")
+
+	assertEntries(t, entryStrings(inner), []string{
+		`trace "router trace"`,
+		`error "files error" k=v`,
+		`info "root info"`,
+		`warn "files warn again"`,
+	})
+}
+
+func TestContext(t *testing.T) {
+	if log.FromContext(context.Background()) != log.GetLogger() {
+		t.Errorf("expected the global logger as fallback")
+	}
+	l := log.New(log.NopSink{}).With("reqid", 1)
+	if log.FromContext(log.NewContext(context.Background(), l)) != l {
+		t.Errorf("expected the logger from the context")
+	}
+}
+
+func listenUnixgram(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := filepath.Join(dir, "sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("unixgram unavailable: %v", err)
+	}
+	return conn, addr, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, addr, cleanup := listenUnixgram(t)
+	defer cleanup()
+	s, err := log.NewSyslogSink(&log.SyslogSinkOpts{
+		Network:          "unixgram",
+		Address:          addr,
+		Facility:         log.SyslogFacilityDaemon,
+		Hostname:         "host",
+		AppName:          "app",
+		StructuredDataID: "fields@32473",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := log.New(s)
+	l.With("quote", `a "b" ]`).Warn("hello")
+
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b[:n])
+	prefix := "<28>1 "
+	suffix := fmt.Sprintf(` host app %d - [fields@32473 quote="a \"b\" \]"] hello`, os.Getpid())
+	if !strings.HasPrefix(msg, prefix) || !strings.HasSuffix(msg, suffix) {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	conn, addr, cleanup := listenUnixgram(t)
+	defer cleanup()
+	s, err := log.NewJournaldSink(&log.JournaldSinkOpts{
+		Address:    addr,
+		Identifier: "app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l := log.New(s)
+	l.Errorw("line1\nline2", log.String("req-id", "x"), log.String("priority", "high"))
+
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "MESSAGE\n\x0b\x00\x00\x00\x00\x00\x00\x00line1\nline2\n" +
+		"PRIORITY=3\nSYSLOG_IDENTIFIER=app\nREQ_ID=x\nF_PRIORITY=high\n"
+	if string(b[:n]) != expected {
+		t.Errorf("expected %q, got %q", expected, b[:n])
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{
+		Formatter: log.JSONFormatter,
+		Stream:    &buf,
+	})
+	log.SetFlags(l, log.FlagStack)
+	l.Warn("no stack")
+	l.Error("with stack")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\r\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+	var rec struct {
+		Stack []struct {
+			Func string
+			File string
+			Line int
+		}
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil || rec.Stack != nil {
+		t.Errorf("unexpected stack below the stack level: %s", lines[0])
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("invalid json %q: %v", lines[1], err)
+	}
+	if len(rec.Stack) == 0 || !strings.HasSuffix(rec.Stack[0].Func, ".TestStackTrace") {
+		t.Fatalf("stack doesn't start at the caller: %+v", rec.Stack)
+	}
+	if !strings.HasSuffix(rec.Stack[0].File, "log_test.go") || rec.Stack[0].Line == 0 {
+		t.Errorf("unexpected frame: %+v", rec.Stack[0])
+	}
+
+	buf.Reset()
+	log.SetStackLevel(l, log.WarnLevel)
+	l.Warnw("typed")
+	if !strings.Contains(buf.String(), `"stack":[{"func":`) {
+		t.Errorf("expected a stack at the warn level: %s", buf.String())
+	}
+}
+
+func logFromHelper(l *log.Logger) {
+	l.WithCallerSkip(1).Info("from helper")
+}
+
+// Small enough to be inlined into its caller.
+func logInlined(l *log.Logger) {
+	l.Info("inlined")
+}
+
+func TestSrcHint(t *testing.T) {
+	l, s := logtest.NewLogger()
+	log.SetFlags(l, log.FlagSrcHint)
+	l.Info("direct")
+	logFromHelper(l)
+
+	recs := s.Entries()
+	for _, m := range recs {
+		if !strings.HasSuffix(m.Function, "log_test.TestSrcHint") {
+			t.Errorf("unexpected caller: %s (%s:%d)", m.Function, m.File, m.Line)
+		}
+	}
+	if len(recs) != 2 || recs[1].Line != recs[0].Line+1 {
+		t.Fatalf("unexpected records: %+v", recs)
+	}
+
+	logInlined(l)
+	if m := s.Entries()[2]; !strings.HasSuffix(m.Function, "log_test.logInlined") {
+		t.Errorf("unexpected caller of an inlined function: %s (%s:%d)", m.Function, m.File, m.Line)
+	}
+
+	file := recs[0].File
+	if got := log.TrimSrcPath(log.SrcPathPackage, file, recs[0].Function); got != "log/log_test.go" {
+		t.Errorf("unexpected package path: %s", got)
+	}
+	if got := log.TrimSrcPath(log.SrcPathModule, file, recs[0].Function); !strings.HasSuffix(got, "log/log_test.go") || strings.HasPrefix(got, "/") {
+		t.Errorf("unexpected module path: %s", got)
+	}
+	if got := log.TrimSrcPath(log.SrcPathFull, file, recs[0].Function); got != file {
+		t.Errorf("unexpected full path: %s", got)
+	}
+}
+
+func TestLogwFrom(t *testing.T) {
+	l, s := logtest.NewLogger()
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	l.LogwFrom(log.InfoLevel, at, pcs[0], "with pc")
+	l.LogwFrom(log.InfoLevel, at, 0, "without pc")
+	l.LogwFrom(log.InfoLevel, time.Time{}, 0, "without time")
+
+	entries := s.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Function, "log_test.TestLogwFrom") {
+			t.Errorf("%s: unexpected caller %s", e.Message, e.Function)
+		}
+	}
+	for _, e := range entries[:2] {
+		if !e.Time.Equal(at) {
+			t.Errorf("%s: expected the given time, got %v", e.Message, e.Time)
+		}
+	}
+	if e := entries[2]; e.Time.IsZero() || e.Time.Equal(at) {
+		t.Errorf("expected the current time, got %v", e.Time)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	const noticeLevel = log.Level(12)
+	if err := log.RegisterLevel(noticeLevel, "notice", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { log.UnregisterLevel(noticeLevel) })
+	if err := log.RegisterLevel(noticeLevel, "other", ""); err == nil {
+		t.Error("expected an error for a registered level")
+	}
+	if err := log.RegisterLevel(log.Level(13), "info", ""); err == nil {
+		t.Error("expected an error for a taken name")
+	}
+	if lvl, err := log.ParseLevel(" Notice"); err != nil || lvl != noticeLevel {
+		t.Errorf("unexpected parse: %v, %v", lvl, err)
+	}
+	if _, err := log.ParseLevel("bogus"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if s := log.LogLevelString(noticeLevel); s != "notice" {
+		t.Errorf("unexpected name: %s", s)
+	}
+
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{Formatter: log.DefaultTextFormatter, Stream: &buf})
+	log.SetFlags(l, 0)
+	log.SetFilter(l, log.LogFilterForLevel(noticeLevel))
+	l.Log(noticeLevel, "one")
+	l.Info("two")
+	func() {
+		defer func() {
+			if r := recover(); r != "three 3" {
+				t.Errorf("unexpected panic: %v", r)
+			}
+		}()
+		l.Panicf("three %d", 3)
+	}()
+	if got := buf.String(); got != "notice\tone\r\npanic\tthree 3\r\n" {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestRingSink(t *testing.T) {
+	s := log.NewRingSink(&log.RingSinkOpts{
+		Size:       2,
+		LevelSizes: map[log.Level]int{log.ErrorLevel: 1},
+	})
+	l := log.New(s)
+	entries, cancel := s.Subscribe(10)
+	l.Error("e1")
+	l.Infof("i%d", 1)
+	l.Error("e2")
+	l.Info("i2")
+	l.With("reqid", 7).Infow("i3", log.Int("n", 3))
+	cancel()
+	l.Info("i4")
+
+	var got []string
+	for _, e := range s.Entries() {
+		got = append(got, log.FormatMessage(&e.Record))
+	}
+	if strings.Join(got, ",") != "e2,i3,i4" {
+		t.Errorf("unexpected entries: %v", got)
+	}
+	if n := len(entries); n != 5 {
+		t.Errorf("expected 5 entries to the subscriber, got %d", n)
+	}
+	for i := 0; i < 4; i++ {
+		<-entries
+	}
+	if e := <-entries; e.Seq != 5 || len(e.Fields) != 1 || len(log.GetFields(e.Meta.Logger)) != 1 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+type apiKey string
+
+func (k apiKey) Redact() string {
+	return string(k[:2]) + "..."
+}
+
+func TestRedactingSink(t *testing.T) {
+	var buf bytes.Buffer
+	opts := log.DefaultRedactingSinkOpts()
+	opts.Patterns = []*regexp.Regexp{regexp.MustCompile(`[\w.]+@[\w.]+`)}
+	l := log.New(log.NewRedactingSink(&log.StreamSink{
+		Formatter: log.LogfmtFormatter,
+		Stream:    &buf,
+	}, &opts))
+	log.SetFlags(l, 0)
+
+	l.With("Authorization", "Bearer xyz").Infow("login by bob@example.com",
+		log.String("password", "hunter2"), log.Any("key", apiKey("sk-123")), log.Int("n", 1))
+	l.Infof("key %s", apiKey("sk-456"))
+	l.Info("nothing to hide")
+
+	want := `level=info msg="login by [redacted]" Authorization=[redacted] password=[redacted] key=sk... n=1` + "\r\n" +
+		`level=info msg="key sk..."` + "\r\n" +
+		`level=info msg="nothing to hide"` + "\r\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestNetworkSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Address = ln.Addr().String()
+	opts.Formatter = log.LogfmtFormatter
+	opts.Framing = log.FramingOctetCounting
+	opts.MinBackoff = 10 * time.Millisecond
+	s := log.NewNetworkSink(&opts)
+	defer s.Close()
+	l := log.New(s)
+	log.SetFlags(l, 0)
+
+	read := func(r *bufio.Reader, want string) {
+		t.Helper()
+		var n int
+		if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("unexpected message %q, want %q", b, want)
+		}
+	}
+
+	l.Info("one")
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read(bufio.NewReader(conn), `level=info msg=one`)
+	conn.Close()
+
+	// Writes to the closed connection fail sooner or later, and the
+	// messages are sent again on a new one.
+	conn2 := make(chan net.Conn)
+	go func() {
+		c, _ := ln.Accept()
+		conn2 <- c
+	}()
+	var c net.Conn
+	for i := 0; c == nil; i++ {
+		l.Infof("two %d", i)
+		select {
+		case c = <-conn2:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	defer c.Close()
+	st := waitNetworkStats(t, s, func(st log.NetworkSinkStats) bool {
+		return st.Spooled == 0 && st.Connected && st.Reconnects > 0
+	})
+	if st.Reconnects != 1 || st.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	l.Info("three")
+	s.Flush()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) == `level=info msg=three` {
+			break
+		}
+		if !strings.HasPrefix(string(b), `level=info msg="two `) {
+			t.Fatalf("unexpected message %q", b)
+		}
+	}
+}
+
+func TestNetworkSinkSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Address = addr
+	opts.SpoolSize = 2
+	opts.MinBackoff = time.Millisecond
+	opts.MaxBackoff = 5 * time.Millisecond
+	s := log.NewNetworkSink(&opts)
+	l := log.New(s)
+	for i := 0; i < 5; i++ {
+		l.Infof("%d", i)
+	}
+	// The one being sent isn't in the spool, so at least 2 are dropped.
+	if st := s.Stats(); st.Dropped < 2 || st.Spooled == 0 || st.Connected {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	s.Flush()
+	s.Close()
+	if st := s.Stats(); st.Dropped != 5 || st.Sent != 0 {
+		t.Errorf("unexpected stats after close %+v", st)
+	}
+}
+
+// listenUnix listens on a unix socket in a temporary directory.
+func listenUnix(t *testing.T) (net.Listener, string) {
+	dir, err := ioutil.TempDir("", "log-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	addr := filepath.Join(dir, "sock")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln, addr
+}
+
+// largeMessage logs a message that doesn't fit in the buffers of a
+// socket, so that it's written in parts, and returns it as it's framed.
+func largeMessage(l *log.Logger) string {
+	msg := strings.Repeat("x", 8<<20)
+	l.Info(msg)
+	return msg + "\n"
+}
+
+func newUnixNetworkSink(addr string) *log.NetworkSink {
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Network = "unix"
+	opts.Address = addr
+	opts.Formatter = log.FormatMessage
+	opts.MinBackoff = time.Millisecond
+	return log.NewNetworkSink(&opts)
+}
+
+func TestNetworkSinkShortWrites(t *testing.T) {
+	ln, addr := listenUnix(t)
+	s := newUnixNetworkSink(addr)
+	defer s.Close()
+	l := log.New(s)
+	want := largeMessage(l)
+
+	// The first connection is closed with the message partly written,
+	// so it's sent again, in full, on the next one.
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(c, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Error("expected the message in full on the new connection")
+	}
+	s.Flush()
+	if st := s.Stats(); st.Sent != 1 || st.Reconnects != 1 || st.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+// stallConn is a peer that only accepts bytes when the test grants them,
+// and whose writes time out when the test says so.
+type stallConn struct {
+	net.Conn
+	grant   chan int
+	timeout chan struct{}
+
+	m         sync.Mutex
+	unlimited bool
+	buf       bytes.Buffer
+}
+
+func newStallConn() *stallConn {
+	return &stallConn{grant: make(chan int), timeout: make(chan struct{})}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Write takes the bytes granted to it, until it's been given all of b, or
+// the test makes it time out. A negative grant accepts everything from
+// then on.
+func (c *stallConn) Write(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		c.m.Lock()
+		if c.unlimited {
+			c.buf.Write(b[n:])
+			c.m.Unlock()
+			return len(b), nil
+		}
+		c.m.Unlock()
+		select {
+		case g := <-c.grant:
+			c.m.Lock()
+			if g < 0 || g > len(b)-n {
+				c.unlimited = g < 0
+				g = len(b) - n
+			}
+			c.buf.Write(b[n : n+g])
+			c.m.Unlock()
+			n += g
+		case <-c.timeout:
+			return n, timeoutError{}
+		}
+	}
+	return n, nil
+}
+
+// stall stops accepting bytes until they're granted again.
+func (c *stallConn) stall() {
+	c.m.Lock()
+	c.unlimited = false
+	c.m.Unlock()
+}
+
+func (c *stallConn) String() string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.buf.String()
+}
+
+func (c *stallConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *stallConn) Close() error                       { return nil }
+
+// waitNetworkStats polls the stats of the sink until ok returns true.
+func waitNetworkStats(t *testing.T, s *log.NetworkSink, ok func(log.NetworkSinkStats) bool) log.NetworkSinkStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := s.Stats()
+		if ok(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected stats %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNetworkSinkWriteTimeout(t *testing.T) {
+	conns := make(chan *stallConn, 2)
+	first, second := newStallConn(), newStallConn()
+	conns <- first
+	conns <- second
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Formatter = log.FormatMessage
+	opts.MinBackoff = time.Millisecond
+	log.SetNetworkSinkDial(&opts, func(network, address string) (net.Conn, error) {
+		return <-conns, nil
+	})
+	s := log.NewNetworkSink(&opts)
+	defer s.Close()
+	l := log.New(s)
+	l.Info("slow")
+	l.Info("next")
+
+	// A write that times out after some of it went out is finished on
+	// the same connection.
+	first.grant <- 2
+	first.timeout <- struct{}{}
+	first.grant <- -1
+	waitNetworkStats(t, s, func(st log.NetworkSinkStats) bool { return st.Sent == 2 })
+	if got := first.String(); got != "slow\nnext\n" {
+		t.Errorf("expected the messages in full on the same connection, got %q", got)
+	}
+
+	// One that times out before any of it went out is sent again on a
+	// new connection.
+	first.stall()
+	l.Info("stuck")
+	first.timeout <- struct{}{}
+	second.grant <- -1
+	st := waitNetworkStats(t, s, func(st log.NetworkSinkStats) bool { return st.Sent == 3 })
+	if st.Reconnects != 1 || st.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if got := second.String(); got != "stuck\n" {
+		t.Errorf("expected the message on the new connection, got %q", got)
+	}
+}
+
+func TestNetworkSinkMaxAttempts(t *testing.T) {
+	ln, addr := listenUnix(t)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	s := newUnixNetworkSink(addr)
+	l := log.New(s)
+	largeMessage(l)
+	for i := 0; s.Stats().Dropped == 0; i++ {
+		if i == 500 {
+			t.Fatalf("expected the message to be dropped, got %+v", s.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Close()
+	if st := s.Stats(); st.Sent != 0 || st.Dropped != 1 || st.Reconnects != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestNetworkSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Network = "udp"
+	opts.Address = pc.LocalAddr().String()
+	opts.Formatter = log.LogfmtFormatter
+	s := log.NewNetworkSink(&opts)
+	defer s.Close()
+	l := log.New(s)
+	log.SetFlags(l, 0)
+
+	// Too large for a datagram, so it's dropped rather than retried.
+	l.Info(strings.Repeat("x", 70000))
+	l.Info("one")
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b[:n]); got != "level=info msg=one\n" {
+		t.Errorf("unexpected datagram %q", got)
+	}
+	s.Flush()
+	if st := s.Stats(); st.Sent != 1 || st.Dropped != 1 || st.Reconnects != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestNetworkSinkUnixgram(t *testing.T) {
+	conn, addr, cleanup := listenUnixgram(t)
+	defer cleanup()
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Network = "unixgram"
+	opts.Address = addr
+	opts.Formatter = log.FormatMessage
+	s := log.NewNetworkSink(&opts)
+	defer s.Close()
+	l := log.New(s)
+	l.Info("one")
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b[:n]); got != "one\n" {
+		t.Errorf("unexpected datagram %q", got)
+	}
+}
+
+func TestNetworkSinkTLS(t *testing.T) {
+	c, err := cert.CreateSelfSignedRandomX509("log-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{c}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	opts := log.DefaultNetworkSinkOpts()
+	opts.Address = ln.Addr().String()
+	opts.Formatter = log.FormatMessage
+	opts.TLSConfig = &tls.Config{RootCAs: roots}
+	s := log.NewNetworkSink(&opts)
+	defer s.Close()
+	l := log.New(s)
+	l.Info("secret")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "secret\n" {
+		t.Errorf("unexpected message %q", line)
+	}
+}
+
+func TestFilterLevel(t *testing.T) {
+	l := log.New(log.NopSink{})
+	log.SetFilterLevel(l, log.WarnLevel)
+	if !l.IsEnabled(log.ErrorLevel) || !l.IsEnabled(log.WarnLevel) || l.IsEnabled(log.InfoLevel) {
+		t.Error("unexpected levels for warn")
+	}
+	log.SetFilterLevel(l, log.DisabledLevel)
+	if l.IsEnabled(log.DisabledLevel) || l.IsEnabled(log.FatalLevel) {
+		t.Error("unexpected levels for disabled")
+	}
+	log.SetFilterLevel(l, log.Level(1000))
+	if !l.IsEnabled(log.InfoLevel) || l.IsEnabled(log.DebugLevel) {
+		t.Error("expected info for an invalid level")
+	}
+	log.SetFilter(l, func(lvl log.Level) bool { return lvl == log.DebugLevel })
+	if !l.IsEnabled(log.DebugLevel) || l.IsEnabled(log.ErrorLevel) {
+		t.Error("expected the filter to be used")
+	}
+}
+
+// TestConcurrentSwitching is meant to be run with -race.
+func TestConcurrentSwitching(t *testing.T) {
+	prev := log.GetLogger()
+	defer log.SetLogger(prev)
+	defer log.ResetLevel("race")
+
+	loggers := []*log.Logger{
+		log.New(log.NopSink{}),
+		log.New(log.NopSink{}),
+	}
+	log.SetLogger(loggers[0])
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				log.Infof("global %d", i)
+				log.Named("race.child").Warn("named")
+				log.With("i", i).Debugw("with", log.Int("n", j))
+				log.FromContext(context.Background()).Error("context")
+				if log.GetLogger().IsEnabled(log.TraceLevel) {
+					log.Trace("trace")
+				}
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	levels := []log.Level{log.ErrorLevel, log.InfoLevel, log.TraceLevel, log.DisabledLevel}
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		l := loggers[i%2]
+		switch i % 5 {
+		case 0:
+			log.SetLogger(l)
+		case 1:
+			log.SetFilterLevel(l, levels[i%len(levels)])
+		case 2:
+			log.SetFilter(l, log.LogFilterForLevel(levels[i%len(levels)]))
+		case 3:
+			log.SetFlags(l, log.FlagTime|log.FlagSrcHint|log.FlagStack)
+			log.SetStackLevel(l, levels[i%len(levels)])
+		case 4:
+			log.SetLevel("race", levels[i%len(levels)])
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{Formatter: log.LogfmtFormatter, Stream: &buf})
+	log.SetFlags(l, 0)
+	log.SetFilterLevel(l, log.InfoLevel)
+
+	var errCount int
+	alerts := make(chan string, 1)
+	l = l.With("app", "x").WithHooks(
+		log.VersionHook("1.2.3"),
+		log.LevelHook(log.ErrorLevel, func(r *log.Record) {
+			errCount++
+			select {
+			case alerts <- log.FormatMessage(r):
+			default:
+			}
+		}),
+	).WithHooks(log.PidHook())
+
+	l.Infow("started", log.Int("n", 1))
+	l.Named("db").Error("lost connection")
+	l.Debug("not enabled")
+	l.Warn("slow")
+
+	pid := os.Getpid()
+	want := fmt.Sprintf(`level=info msg=started app=x n=1 version=1.2.3 pid=%d`+"\r\n"+
+		`level=error msg="lost connection" app=x version=1.2.3 pid=%d`+"\r\n"+
+		`level=warn msg=slow app=x version=1.2.3 pid=%d`+"\r\n", pid, pid, pid)
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if errCount != 1 || <-alerts != "lost connection" {
+		t.Errorf("expected an error alert, got %d errors", errCount)
+	}
+
+	buf.Reset()
+	l = log.New(&log.StreamSink{Formatter: log.LogfmtFormatter, Stream: &buf}).WithHooks(log.GoroutineIDHook())
+	log.SetFlags(l, 0)
+	l.Info("hi")
+	if !regexp.MustCompile(`^level=info msg=hi goroutine=[1-9]\d*\r\n$`).MatchString(buf.String()) {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+type causeErr struct {
+	msg   string
+	cause error
+}
+
+func (e *causeErr) Error() string { return e.msg }
+func (e *causeErr) Cause() error  { return e.cause }
+
+func TestHumanFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&log.StreamSink{
+		Formatter: log.NewHumanFormatter(&log.HumanFormatterOpts{PrettyStructs: true}),
+		Stream:    &buf,
+	})
+	log.SetFlags(l, 0)
+
+	root := errors.New("permission denied")
+	err := &causeErr{"serve /a", fmt.Errorf("open a.txt: %w", root)}
+	l.Errorw("request failed\nretrying", log.Err(err), log.String("body", "a\r\nb"))
+	l.Infow("config", log.Any("opts", struct {
+		Size int      `json:"size"`
+		Tags []string `json:"tags"`
+	}{2, []string{"x"}}), log.Any("at", time.Duration(0)))
+
+	want := "error  request failed\r\n" +
+		"       retrying error=serve /a\r\n" +
+		"       └─ open a.txt\r\n" +
+		"          └─ permission denied  body=a\r\n" +
+		"       b \r\n" +
+		"info   config opts={\r\n" +
+		"         \"size\": 2,\r\n" +
+		"         \"tags\": [\r\n" +
+		"           \"x\"\r\n" +
+		"         ]\r\n" +
+		"       }  at=0s \r\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	l = log.New(&log.StreamSink{Formatter: log.LogfmtFormatter, Stream: &buf})
+	log.SetFlags(l, 0)
+	l.Errorw("a\nb", log.Err(err))
+	if s := buf.String(); strings.Count(s, "\n") != 1 {
+		t.Errorf("expected a single line, got %q", s)
+	}
+}
+
+type colorValue struct{}
+
+func (colorValue) String() string      { return "plain" }
+func (colorValue) ColorString() string { return "\x1b[1mcolor\x1b[0m" }
+
+func TestHumanFormatterColorArgs(t *testing.T) {
+	var colored, plain bytes.Buffer
+	l := log.New(log.CreateMultiSink(
+		&log.StreamSink{
+			Formatter: log.NewHumanFormatter(&log.HumanFormatterOpts{Color: true}),
+			Stream:    &colored,
+		},
+		&log.StreamSink{Formatter: log.FormatMessage, Stream: &plain},
+	))
+	log.SetFlags(l, 0)
+	args := []interface{}{colorValue{}}
+	l.Infof("value %v", args...)
+
+	if !strings.Contains(colored.String(), "value \x1b[1mcolor\x1b[0m") {
+		t.Errorf("expected the colored value, got %q", colored.String())
+	}
+	// The record isn't changed for the sinks after the colored one.
+	if plain.String() != "value plain" {
+		t.Errorf("expected the plain value, got %q", plain.String())
+	}
+	if _, ok := args[0].(colorValue); !ok {
+		t.Errorf("expected the args to be left alone, got %#v", args[0])
+	}
+}
+
+func TestDedupSink(t *testing.T) {
+	inner := logtest.NewCapturingSink()
+	s := log.NewDedupSink(inner, &log.DedupSinkOpts{Window: time.Hour})
+	l := log.New(s)
+	log.SetFlags(l, 0)
+	for i := 0; i < 3; i++ {
+		l.Errorw("failed", log.Int("id", 1))
+		l.Errorw("failed", log.Int("id", 2))
+		l.Warn("slow")
+	}
+	l.Errorw("failed", log.Int("id", 1))
+	l.Info("once")
+	s.Flush()
+
+	assertEntries(t, entryStrings(inner), []string{
+		`error "failed" id=1`,
+		`error "failed" id=2`,
+		`warn "slow"`,
+		`info "once"`,
+		`error "failed" id=1 repeated=3 first=...`,
+		`error "failed" id=2 repeated=2 first=...`,
+		`warn "slow" repeated=2 first=...`,
+	})
+	if !inner.Has(logtest.HasField("first"), logtest.HasField("last")) {
+		t.Errorf("expected the first and last times in %q", entryStrings(inner))
+	}
+
+	inner.Reset()
+	s = log.NewDedupSink(inner, &log.DedupSinkOpts{Window: time.Hour, Consecutive: true})
+	l = log.New(s)
+	log.SetFlags(l, 0)
+	l.Warn("a")
+	l.Warn("a")
+	l.Warn("b")
+	l.Warn("a")
+	s.Flush()
+	assertEntries(t, entryStrings(inner), []string{
+		`warn "a"`, `warn "a" repeated=1 ...`, `warn "b"`, `warn "a"`,
+	})
+}
+
+func TestDedupSinkWindow(t *testing.T) {
+	inner := logtest.NewCapturingSink()
+	s := log.NewDedupSink(inner, &log.DedupSinkOpts{Window: 10 * time.Millisecond})
+	l := log.New(s)
+	log.SetFlags(l, 0)
+	l.Error("boom")
+	l.Error("boom")
+	// The repeats are logged at the end of the window.
+	assertEntries(t, waitEntries(t, inner, 2), []string{
+		`error "boom"`, `error "boom" repeated=1 ...`,
+	})
+}
+
+func TestSamplingSinkInterval(t *testing.T) {
+	inner := logtest.NewCapturingSink()
+	s := log.NewSamplingSink(inner, &log.SamplingSinkOpts{
+		Interval: 10 * time.Millisecond,
+		First:    1,
+	})
+	l := log.New(s)
+	log.SetFlags(l, log.FlagSrcHint)
+	for i := 0; i < 3; i++ {
+		l.Warnv("failed", i)
+	}
+	// A different call site is sampled on its own.
+	l.Warnv("failed", 3)
+	// The summary is logged at the end of the interval.
+	entries := waitEntries(t, inner, 3)
+	assertEntries(t, entries, []string{
+		`warn "failed0"`, `warn "failed3"`, `warn "sampling: suppressed 2 occurrences of ...`,
+	})
+	if !strings.Contains(entries[2], "log_test.go:") {
+		t.Errorf("expected the call site in %q", entries[2])
+	}
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter renders each record as a logfmt line of key=value
+// pairs. Values are quoted and escaped whenever they contain spaces,
+// quotes, equal signs or control characters, so that every line can be
+// split unambiguously by logfmt parsers.
+func LogfmtFormatter(r *Record) string {
+	var buf bytes.Buffer
+	f := GetFlags(r.Meta.Logger)
+	if f&FlagTime == FlagTime {
+		buf.WriteString("time=")
+		writeLogfmtValue(&buf, r.Meta.Time.Format(time.RFC3339Nano))
+		buf.WriteByte(' ')
+	}
+	buf.WriteString("level=")
+	writeLogfmtValue(&buf, LogLevelString(r.Meta.Level))
+	buf.WriteString(" msg=")
+	args := r.Args
+	if r.Format == "" {
+		writeLogfmtValue(&buf, fmt.Sprint(args...))
+	} else if len(args) > 0 {
+		writeLogfmtValue(&buf, fmt.Sprintf(r.Format, args...))
+	} else {
+		writeLogfmtValue(&buf, r.Format)
+	}
+	if f&FlagSrcHint == FlagSrcHint {
+		buf.WriteString(" caller=")
+		writeLogfmtValue(&buf, r.Meta.File+":"+strconv.Itoa(r.Meta.Line))
+		if r.Meta.Function != "" {
+			buf.WriteString(" func=")
+			writeLogfmtValue(&buf, r.Meta.Function)
+		}
+	}
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			buf.WriteByte(' ')
+			writeLogfmtKey(&buf, x.Name)
+			buf.WriteByte('=')
+			writeLogfmtValue(&buf, logfmtValueString(x))
+		}
+	}
+	if len(r.Stack) > 0 {
+		buf.WriteString(" stack=")
+		writeLogfmtValue(&buf, StackString(r.Stack))
+	}
+	buf.WriteString("\r\n")
+	return buf.String()
+}
+
+func logfmtValueString(f Field) string {
+	if f.Kind != AnyKind {
+		return string(f.AppendText(nil))
+	}
+	switch x := f.Value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return x
+	case error:
+		return x.Error()
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case []byte:
+		return string(x)
+	}
+	return fmt.Sprint(f.Value)
+}
+
+// Keys cannot be quoted in logfmt, so any character that would break
+// the pair is replaced instead.
+func writeLogfmtKey(buf *bytes.Buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for _, c := range key {
+		if c <= ' ' || c == '=' || c == '"' || c == utf8.RuneError || !unicode.IsPrint(c) {
+			buf.WriteByte('_')
+		} else {
+			buf.WriteRune(c)
+		}
+	}
+}
+
+func writeLogfmtValue(buf *bytes.Buffer, value string) {
+	if logfmtNeedsQuoting(value) {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+func logfmtNeedsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	return strings.IndexFunc(s, func(c rune) bool {
+		return c <= ' ' || c == '=' || c == '"' || c == '\\' || c == utf8.RuneError || !unicode.IsPrint(c)
+	}) >= 0
+}
@@ -0,0 +1,243 @@
+// Package logtest helps tests assert what has been logged.
+package logtest
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+// Entry is a captured record. Fields holds the fields of the logger,
+// followed by the ones of the record.
+type Entry struct {
+	Level    log.Level
+	Message  string
+	Fields   []log.Field
+	Time     time.Time
+	File     string
+	Line     int
+	Function string
+}
+
+// Field returns the field of the given name, preferring the ones of the
+// record over the ones of the logger.
+func (e *Entry) Field(name string) (log.Field, bool) {
+	for i := len(e.Fields) - 1; i >= 0; i-- {
+		if e.Fields[i].Name == name {
+			return e.Fields[i], true
+		}
+	}
+	return log.Field{}, false
+}
+
+func (e *Entry) String() string {
+	var b strings.Builder
+	b.WriteString(log.LogLevelString(e.Level) + " " + strconv.Quote(e.Message))
+	var scratch []byte
+	for _, f := range e.Fields {
+		scratch = f.AppendText(scratch[:0])
+		b.WriteString(" " + f.Name + "=" + string(scratch))
+	}
+	return b.String()
+}
+
+// CapturingSink keeps every record logged to it.
+type CapturingSink struct {
+	m       sync.Mutex
+	entries []Entry
+}
+
+func NewCapturingSink() *CapturingSink {
+	return &CapturingSink{}
+}
+
+func (s *CapturingSink) Log(r *log.Record) {
+	e := Entry{
+		Level:    r.Meta.Level,
+		Message:  log.FormatMessage(r),
+		Time:     r.Meta.Time,
+		File:     r.Meta.File,
+		Line:     r.Meta.Line,
+		Function: r.Meta.Function,
+	}
+	loggerFields := log.GetFields(r.Meta.Logger)
+	if n := len(loggerFields) + len(r.Fields); n > 0 {
+		e.Fields = make([]log.Field, 0, n)
+		e.Fields = append(e.Fields, loggerFields...)
+		e.Fields = append(e.Fields, r.Fields...)
+	}
+	s.m.Lock()
+	s.entries = append(s.entries, e)
+	s.m.Unlock()
+}
+
+func (s *CapturingSink) Flush() {}
+
+// Entries returns a copy of the captured entries, oldest first.
+func (s *CapturingSink) Entries() []Entry {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
+
+func (s *CapturingSink) Reset() {
+	s.m.Lock()
+	s.entries = nil
+	s.m.Unlock()
+}
+
+// Filter returns the captured entries that satisfy all the matchers.
+func (s *CapturingSink) Filter(matchers ...Matcher) []Entry {
+	var res []Entry
+	for _, e := range s.Entries() {
+		if matchAll(&e, matchers) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Has reports whether any of the captured entries satisfy all the
+// matchers.
+func (s *CapturingSink) Has(matchers ...Matcher) bool {
+	return len(s.Filter(matchers...)) > 0
+}
+
+// AssertLogged fails the test if none of the captured entries satisfy
+// all the matchers.
+func (s *CapturingSink) AssertLogged(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+	if !s.Has(matchers...) {
+		t.Errorf("logtest: no entry matched %s, in:\n%s", describe(matchers), s.dump())
+	}
+}
+
+// AssertNotLogged fails the test if any of the captured entries satisfy
+// all the matchers.
+func (s *CapturingSink) AssertNotLogged(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+	if found := s.Filter(matchers...); len(found) > 0 {
+		t.Errorf("logtest: unexpected entry matched %s: %s", describe(matchers), found[0].String())
+	}
+}
+
+func (s *CapturingSink) dump() string {
+	var b strings.Builder
+	for _, e := range s.Entries() {
+		b.WriteString("\t" + e.String() + "\n")
+	}
+	return b.String()
+}
+
+// NewLogger returns a logger of all the levels, with the source hint,
+// that writes to a new capturing sink.
+func NewLogger() (*log.Logger, *CapturingSink) {
+	s := NewCapturingSink()
+	l := log.New(s)
+	log.SetFlags(l, log.FlagTime|log.FlagSrcHint)
+	return l, s
+}
+
+// Install makes a capturing logger the global logger, until the end
+// of the test.
+func Install(t testing.TB) *CapturingSink {
+	prev := log.GetLogger()
+	l, s := NewLogger()
+	log.SetLogger(l)
+	t.Cleanup(func() {
+		log.SetLogger(prev)
+	})
+	return s
+}
+
+// Matcher reports whether an entry satisfies a condition. Desc describes
+// the condition in failures.
+type Matcher struct {
+	Desc  string
+	Match func(e *Entry) bool
+}
+
+func matchAll(e *Entry, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !m.Match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "(anything)"
+	}
+	descs := make([]string, len(matchers))
+	for i, m := range matchers {
+		descs[i] = m.Desc
+	}
+	return strings.Join(descs, ", ")
+}
+
+func Level(lvl log.Level) Matcher {
+	return Matcher{
+		Desc:  "level " + log.LogLevelString(lvl),
+		Match: func(e *Entry) bool { return e.Level == lvl },
+	}
+}
+
+// LevelAtLeast matches entries of the level or more severe.
+func LevelAtLeast(lvl log.Level) Matcher {
+	return Matcher{
+		Desc:  "level " + log.LogLevelString(lvl) + " or more severe",
+		Match: func(e *Entry) bool { return e.Level <= lvl },
+	}
+}
+
+func Message(msg string) Matcher {
+	return Matcher{
+		Desc:  "message " + strconv.Quote(msg),
+		Match: func(e *Entry) bool { return e.Message == msg },
+	}
+}
+
+func MessageContains(substr string) Matcher {
+	return Matcher{
+		Desc:  "message containing " + strconv.Quote(substr),
+		Match: func(e *Entry) bool { return strings.Contains(e.Message, substr) },
+	}
+}
+
+// HasField matches entries that have the field, of any value.
+func HasField(name string) Matcher {
+	return Matcher{
+		Desc: "field " + name,
+		Match: func(e *Entry) bool {
+			_, ok := e.Field(name)
+			return ok
+		},
+	}
+}
+
+// Field matches entries with the field of the value. Values are equal if
+// they're deeply equal, or if their text is, so that log.Int("n", 1)
+// matches a value of 1 or "1".
+func Field(name string, value interface{}) Matcher {
+	return Matcher{
+		Desc: fmt.Sprintf("field %s=%v", name, value),
+		Match: func(e *Entry) bool {
+			f, ok := e.Field(name)
+			if !ok {
+				return false
+			}
+			if reflect.DeepEqual(f.Interface(), value) {
+				return true
+			}
+			return string(f.AppendText(nil)) == fmt.Sprint(value)
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package logtest_test
+
+import (
+	"testing"
+
+	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/go-gluons/log/logtest"
+)
+
+func TestInstall(t *testing.T) {
+	prev := log.GetLogger()
+	t.Run("captured", func(t *testing.T) {
+		s := logtest.Install(t)
+		log.With("reqid", "abc").Warnw("slow request", log.Int("ms", 1200))
+		log.Debugf("cache %s", "miss")
+
+		s.AssertLogged(t, logtest.Level(log.WarnLevel), logtest.Message("slow request"),
+			logtest.Field("reqid", "abc"), logtest.Field("ms", 1200))
+		s.AssertLogged(t, logtest.MessageContains("miss"), logtest.LevelAtLeast(log.DebugLevel))
+		s.AssertNotLogged(t, logtest.LevelAtLeast(log.ErrorLevel))
+		if s.Has(logtest.Field("ms", 1)) || s.Has(logtest.HasField("missing")) {
+			t.Error("unexpected field match")
+		}
+		if e := s.Entries()[0]; e.Line == 0 || e.Function == "" {
+			t.Errorf("expected the source hint: %+v", e)
+		}
+	})
+	if log.GetLogger() != prev {
+		t.Error("global logger wasn't restored")
+	}
+}
@@ -0,0 +1,176 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Named loggers form a dot separated hierarchy, like "http.fileserver".
+// A level set for a name applies to that logger and all of its
+// descendants, unless a more specific name has a level of its own.
+// Loggers with no level set for any of their ancestors fall back to
+// their own filter.
+
+const noLevel = -1
+
+type levelNode struct {
+	level int64
+}
+
+func (n *levelNode) load() (Level, bool) {
+	l := atomic.LoadInt64(&n.level)
+	if l == noLevel {
+		return 0, false
+	}
+	return Level(l), true
+}
+
+type registry struct {
+	m       sync.RWMutex
+	rules   map[string]Level
+	nodes   map[string]*levelNode
+	loggers map[string]*Logger
+	// gen counts the resets of loggers, so that a logger derived from
+	// a global logger that has since been replaced isn't cached.
+	gen uint64
+}
+
+var names = registry{
+	rules:   make(map[string]Level),
+	nodes:   make(map[string]*levelNode),
+	loggers: make(map[string]*Logger),
+}
+
+func (r *registry) node(name string) *levelNode {
+	r.m.RLock()
+	n := r.nodes[name]
+	r.m.RUnlock()
+	if n != nil {
+		return n
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if n = r.nodes[name]; n == nil {
+		n = &levelNode{level: r.resolve(name)}
+		r.nodes[name] = n
+	}
+	return n
+}
+
+func (r *registry) resolve(name string) int64 {
+	for {
+		if lvl, ok := r.rules[name]; ok {
+			return int64(lvl)
+		}
+		if name == "" {
+			return noLevel
+		}
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		} else {
+			name = ""
+		}
+	}
+}
+
+func (r *registry) update() {
+	for name, n := range r.nodes {
+		atomic.StoreInt64(&n.level, r.resolve(name))
+	}
+}
+
+func joinName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" {
+		return parent
+	}
+	return parent + "." + name
+}
+
+// Named returns a child logger, whose name is the name of this logger
+// followed by the given name, separated by a dot.
+func (l *Logger) Named(name string) *Logger {
+	name = joinName(l.name, name)
+	x := l.derive()
+	x.name = name
+	x.node = names.node(name)
+	return x
+}
+
+// Named returns the logger of the given name derived from the global
+// logger. They are cached until the global logger is replaced, so it
+// is cheap enough to be called for each use.
+func Named(name string) *Logger {
+	names.m.RLock()
+	l := names.loggers[name]
+	gen := names.gen
+	names.m.RUnlock()
+	if l != nil {
+		return l
+	}
+	l = GetLogger().Named(name)
+	names.m.Lock()
+	if x, ok := names.loggers[name]; ok {
+		l = x
+	} else if gen == names.gen {
+		names.loggers[name] = l
+	}
+	names.m.Unlock()
+	return l
+}
+
+func resetNamedLoggers() {
+	names.m.Lock()
+	names.loggers = make(map[string]*Logger)
+	names.gen++
+	names.m.Unlock()
+}
+
+func GetName(logger *Logger) string {
+	return logger.name
+}
+
+// SetLevel sets the level of the named loggers with the given name, or
+// the name as their prefix. An empty name sets it for every named logger.
+func SetLevel(name string, lvl Level) {
+	names.m.Lock()
+	defer names.m.Unlock()
+	names.rules[name] = lvl
+	names.update()
+}
+
+// ResetLevel removes the level set by SetLevel, so that the loggers
+// inherit it again from their ancestors.
+func ResetLevel(name string) {
+	names.m.Lock()
+	defer names.m.Unlock()
+	delete(names.rules, name)
+	names.update()
+}
+
+// GetLevels returns the names that have a level set, along with them.
+func GetLevels() map[string]Level {
+	names.m.RLock()
+	defer names.m.RUnlock()
+	res := make(map[string]Level, len(names.rules))
+	for k, v := range names.rules {
+		res[k] = v
+	}
+	return res
+}
+
+// GetNames returns the names of all the loggers created so far, sorted.
+func GetNames() []string {
+	names.m.RLock()
+	defer names.m.RUnlock()
+	res := make([]string, 0, len(names.nodes))
+	for k := range names.nodes {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
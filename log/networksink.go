@@ -0,0 +1,323 @@
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type Framing int
+
+const (
+	// FramingNewline ends each message with a newline.
+	FramingNewline Framing = iota
+	// FramingOctetCounting prefixes each message with its length and a
+	// space, as in RFC 6587.
+	FramingOctetCounting
+)
+
+type NetworkSinkOpts struct {
+	// Network is one of "tcp", "udp", "unix" or "unixgram".
+	Network   string
+	Address   string
+	Formatter func(*Record) string
+	Framing   Framing
+	// TLSConfig, when set, secures tcp connections.
+	TLSConfig *tls.Config
+	// SpoolSize is the number of messages kept while the peer can't be
+	// reached. The oldest are dropped to make room for new ones.
+	SpoolSize    int
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Reconnects are retried with a delay that doubles from MinBackoff
+	// up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of times the write of a message is tried,
+	// each on a new connection, before it's dropped. Messages too large
+	// for a datagram are dropped right away. It's also the number of
+	// failed dials in a row after which Flush stops waiting for the peer.
+	MaxAttempts int
+
+	// dial replaces the dialer in tests.
+	dial func(network, address string) (net.Conn, error)
+}
+
+func DefaultNetworkSinkOpts() NetworkSinkOpts {
+	return NetworkSinkOpts{
+		Network:      "tcp",
+		Formatter:    JSONFormatter,
+		Framing:      FramingNewline,
+		SpoolSize:    1024,
+		DialTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		MinBackoff:   100 * time.Millisecond,
+		MaxBackoff:   30 * time.Second,
+		MaxAttempts:  3,
+	}
+}
+
+type NetworkSinkStats struct {
+	Sent       uint64
+	Dropped    uint64
+	Reconnects uint64
+	Spooled    int
+	Connected  bool
+}
+
+// NetworkSink streams the formatted records to a collector. Records are
+// formatted as they're logged, and sent by a background goroutine, so
+// that logging never waits on the network.
+type NetworkSink struct {
+	opts NetworkSinkOpts
+
+	m       sync.Mutex
+	changed sync.Cond
+	spool   [][]byte
+	busy    bool
+	closed  bool
+	stats   NetworkSinkStats
+	// connected is set once the first connection is made, after which
+	// every other is counted as a reconnect.
+	connected bool
+	// dialFailures is the number of failed dials since the last
+	// connection was made.
+	dialFailures int
+	conn         net.Conn
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func NewNetworkSink(opts *NetworkSinkOpts) *NetworkSink {
+	if opts == nil {
+		o := DefaultNetworkSinkOpts()
+		opts = &o
+	}
+	s := &NetworkSink{
+		opts: *opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if s.opts.Formatter == nil {
+		s.opts.Formatter = JSONFormatter
+	}
+	if s.opts.SpoolSize < 1 {
+		s.opts.SpoolSize = 1
+	}
+	if s.opts.MinBackoff <= 0 {
+		s.opts.MinBackoff = 100 * time.Millisecond
+	}
+	if s.opts.MaxBackoff < s.opts.MinBackoff {
+		s.opts.MaxBackoff = s.opts.MinBackoff
+	}
+	if s.opts.MaxAttempts < 1 {
+		s.opts.MaxAttempts = 1
+	}
+	s.changed.L = &s.m
+	go s.run()
+	return s
+}
+
+func (s *NetworkSink) Log(r *Record) {
+	msg := s.frame(s.opts.Formatter(r))
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		s.stats.Dropped++
+		return
+	}
+	if len(s.spool) >= s.opts.SpoolSize {
+		s.spool[0] = nil
+		s.spool = s.spool[1:]
+		s.stats.Dropped++
+	}
+	s.spool = append(s.spool, msg)
+	s.changed.Broadcast()
+}
+
+func (s *NetworkSink) frame(formatted string) []byte {
+	formatted = strings.TrimRight(formatted, "\r\n")
+	if s.opts.Framing == FramingOctetCounting {
+		msg := strconv.AppendInt(nil, int64(len(formatted)), 10)
+		msg = append(msg, ' ')
+		return append(msg, formatted...)
+	}
+	return []byte(formatted + "\n")
+}
+
+// Flush waits until the spooled messages have been sent, including
+// through reconnects. It returns early once the peer can't be reached
+// for MaxAttempts dials in a row, rather than waiting for it.
+func (s *NetworkSink) Flush() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for (len(s.spool) > 0 || s.busy) && s.dialFailures < s.opts.MaxAttempts && !s.closed {
+		s.changed.Wait()
+	}
+}
+
+// Close sends what's been spooled if the peer is connected, and closes
+// the connection. Messages that can't be sent are counted as dropped.
+func (s *NetworkSink) Close() error {
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.changed.Broadcast()
+	s.m.Unlock()
+	<-s.done
+	return nil
+}
+
+func (s *NetworkSink) Stats() NetworkSinkStats {
+	s.m.Lock()
+	defer s.m.Unlock()
+	st := s.stats
+	st.Spooled = len(s.spool)
+	return st
+}
+
+func (s *NetworkSink) run() {
+	defer close(s.done)
+	backoff := s.opts.MinBackoff
+	var msg []byte
+	// written is how much of msg went out on the current connection, and
+	// attempts the number of connections it failed on.
+	var written, attempts int
+	for {
+		if msg == nil {
+			s.m.Lock()
+			for len(s.spool) == 0 && !s.closed {
+				s.changed.Wait()
+			}
+			if len(s.spool) == 0 {
+				s.m.Unlock()
+				break
+			}
+			msg = s.spool[0]
+			s.spool[0] = nil
+			s.spool = s.spool[1:]
+			s.busy = true
+			s.m.Unlock()
+			written, attempts = 0, 0
+		}
+		if s.conn == nil {
+			if err := s.connect(); err != nil {
+				s.m.Lock()
+				s.dialFailures++
+				s.changed.Broadcast()
+				s.m.Unlock()
+				select {
+				case <-time.After(backoff):
+				case <-s.stop:
+					s.m.Lock()
+					s.stats.Dropped += uint64(len(s.spool)) + 1
+					s.spool = nil
+					s.busy = false
+					s.m.Unlock()
+					return
+				}
+				if backoff *= 2; backoff > s.opts.MaxBackoff {
+					backoff = s.opts.MaxBackoff
+				}
+				continue
+			}
+			backoff = s.opts.MinBackoff
+		}
+		if s.opts.WriteTimeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.opts.WriteTimeout))
+		}
+		n, err := s.conn.Write(msg[written:])
+		written += n
+		if err != nil {
+			if isTimeout(err) && n > 0 {
+				// The peer is slow, rather than gone, so the rest
+				// of it is written on the same connection.
+				continue
+			}
+			if errors.Is(err, syscall.EMSGSIZE) {
+				s.finish(false)
+				msg = nil
+				continue
+			}
+			// A message that was cut short is sent again in full
+			// on the next connection.
+			s.disconnect()
+			written = 0
+			if attempts++; attempts >= s.opts.MaxAttempts {
+				s.finish(false)
+				msg = nil
+			}
+			continue
+		}
+		s.finish(true)
+		msg = nil
+	}
+	s.disconnect()
+}
+
+// finish accounts for the message that was being sent, as sent or
+// dropped.
+func (s *NetworkSink) finish(sent bool) {
+	s.m.Lock()
+	if sent {
+		s.stats.Sent++
+	} else {
+		s.stats.Dropped++
+	}
+	s.busy = false
+	s.changed.Broadcast()
+	s.m.Unlock()
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (s *NetworkSink) connect() error {
+	d := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.opts.dial != nil {
+		conn, err = s.opts.dial(s.opts.Network, s.opts.Address)
+	} else if s.opts.TLSConfig != nil && s.opts.Network == "tcp" {
+		conn, err = tls.DialWithDialer(d, s.opts.Network, s.opts.Address, s.opts.TLSConfig)
+	} else {
+		conn, err = d.Dial(s.opts.Network, s.opts.Address)
+	}
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	s.conn = conn
+	if s.connected {
+		s.stats.Reconnects++
+	}
+	s.connected = true
+	s.dialFailures = 0
+	s.stats.Connected = true
+	s.changed.Broadcast()
+	s.m.Unlock()
+	return nil
+}
+
+func (s *NetworkSink) disconnect() {
+	if s.conn == nil {
+		return
+	}
+	s.conn.Close()
+	s.m.Lock()
+	s.conn = nil
+	s.stats.Connected = false
+	s.changed.Broadcast()
+	s.m.Unlock()
+}
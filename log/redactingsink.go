@@ -0,0 +1,137 @@
+package log
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redactor is implemented by values that carry secrets, to be logged
+// as the string returned by Redact instead.
+type Redactor interface {
+	Redact() string
+}
+
+type RedactingSinkOpts struct {
+	// Fields are the names of the fields whose values are masked,
+	// compared case insensitively.
+	Fields []string
+	// Patterns are masked wherever they match in the message.
+	Patterns []*regexp.Regexp
+	Mask     string
+}
+
+func DefaultRedactingSinkOpts() RedactingSinkOpts {
+	return RedactingSinkOpts{
+		Fields: []string{"password", "passwd", "secret", "token", "authorization", "cookie", "set-cookie"},
+		Mask:   "[redacted]",
+	}
+}
+
+// RedactingSink masks secrets in records before they're passed on to the
+// inner sink, and so before they reach the formatters. Records that have
+// nothing to mask are passed on as they are.
+type RedactingSink struct {
+	inner    Sink
+	fields   map[string]struct{}
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+func NewRedactingSink(inner Sink, opts *RedactingSinkOpts) *RedactingSink {
+	if opts == nil {
+		o := DefaultRedactingSinkOpts()
+		opts = &o
+	}
+	s := &RedactingSink{
+		inner:    inner,
+		fields:   make(map[string]struct{}, len(opts.Fields)),
+		patterns: opts.Patterns,
+		mask:     opts.Mask,
+	}
+	for _, name := range opts.Fields {
+		s.fields[strings.ToLower(name)] = struct{}{}
+	}
+	return s
+}
+
+func (s *RedactingSink) Log(r *Record) {
+	x := *r
+	changed := false
+	if l := r.Meta.Logger; l != nil {
+		if fields, ok := s.redactFields(l.fields); ok {
+			rl := l.derive()
+			rl.fields = fields
+			x.Meta.Logger = rl
+			changed = true
+		}
+	}
+	if fields, ok := s.redactFields(r.Fields); ok {
+		x.Fields = fields
+		changed = true
+	}
+	if args, ok := s.redactArgs(r.Args); ok {
+		x.Args = args
+		changed = true
+	}
+	if len(s.patterns) > 0 {
+		msg := FormatMessage(&x)
+		redacted := msg
+		for _, p := range s.patterns {
+			redacted = p.ReplaceAllLiteralString(redacted, s.mask)
+		}
+		if redacted != msg {
+			x.Format = redacted
+			x.Args = nil
+			changed = true
+		}
+	}
+	if changed {
+		s.inner.Log(&x)
+	} else {
+		s.inner.Log(r)
+	}
+}
+
+func (s *RedactingSink) Flush() {
+	s.inner.Flush()
+}
+
+func (s *RedactingSink) redactField(f Field) (Field, bool) {
+	if _, ok := s.fields[strings.ToLower(f.Name)]; ok {
+		return String(f.Name, s.mask), true
+	}
+	if f.Kind == AnyKind || f.Kind == ErrorKind {
+		if x, ok := f.Value.(Redactor); ok {
+			return String(f.Name, x.Redact()), true
+		}
+	}
+	return f, false
+}
+
+// redactFields returns a copy of fields with the secrets masked, if
+// there are any.
+func (s *RedactingSink) redactFields(fields []Field) ([]Field, bool) {
+	var res []Field
+	for i, f := range fields {
+		if rf, ok := s.redactField(f); ok {
+			if res == nil {
+				res = append([]Field(nil), fields...)
+			}
+			res[i] = rf
+		}
+	}
+	return res, res != nil
+}
+
+func (s *RedactingSink) redactArgs(args []interface{}) ([]interface{}, bool) {
+	var res []interface{}
+	for i, a := range args {
+		if x, ok := a.(Redactor); ok {
+			if res == nil {
+				res = append([]interface{}(nil), args...)
+			}
+			res[i] = x.Redact()
+		}
+	}
+	return res, res != nil
+}
@@ -0,0 +1,152 @@
+package log
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type RingSinkOpts struct {
+	// Size is the number of records kept, for the levels that are not in
+	// LevelSizes.
+	Size int
+	// LevelSizes keeps a separate ring for each of the levels in it, so
+	// that, for instance, errors aren't pushed out by a burst of debug
+	// records.
+	LevelSizes map[Level]int
+}
+
+func DefaultRingSinkOpts() RingSinkOpts {
+	return RingSinkOpts{
+		Size: 1000,
+	}
+}
+
+// RingEntry is a record kept by the RingSink, which can be rendered with
+// any of the formatters. The message is rendered when it's logged, so
+// Args is always empty.
+type RingEntry struct {
+	Seq uint64
+	Record
+}
+
+type ring struct {
+	entries []RingEntry
+	next    int
+	full    bool
+}
+
+func (r *ring) add(e RingEntry) {
+	r.entries[r.next] = e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+func (r *ring) appendTo(dst []RingEntry) []RingEntry {
+	if r.full {
+		dst = append(dst, r.entries[r.next:]...)
+	}
+	return append(dst, r.entries[:r.next]...)
+}
+
+// RingSink keeps the most recent records in memory, to be looked at
+// later, and notifies subscribers of each new one.
+type RingSink struct {
+	m      sync.Mutex
+	shared *ring
+	levels map[Level]*ring
+	seq    uint64
+	subs   map[chan RingEntry]struct{}
+}
+
+func NewRingSink(opts *RingSinkOpts) *RingSink {
+	if opts == nil {
+		o := DefaultRingSinkOpts()
+		opts = &o
+	}
+	newRing := func(size int) *ring {
+		if size < 1 {
+			size = 1
+		}
+		return &ring{entries: make([]RingEntry, size)}
+	}
+	s := &RingSink{
+		shared: newRing(opts.Size),
+		levels: make(map[Level]*ring, len(opts.LevelSizes)),
+		subs:   make(map[chan RingEntry]struct{}),
+	}
+	for lvl, size := range opts.LevelSizes {
+		s.levels[lvl] = newRing(size)
+	}
+	return s
+}
+
+func (s *RingSink) Log(r *Record) {
+	e := RingEntry{Record: Record{
+		Meta:   r.Meta,
+		Format: FormatMessage(r),
+	}}
+	if e.Meta.Time.IsZero() {
+		e.Meta.Time = time.Now()
+	}
+	if len(r.Fields) > 0 {
+		e.Fields = append([]Field(nil), r.Fields...)
+	}
+	if len(r.Stack) > 0 {
+		e.Stack = append([]Frame(nil), r.Stack...)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.seq++
+	e.Seq = s.seq
+	if x, ok := s.levels[e.Meta.Level]; ok {
+		x.add(e)
+	} else {
+		s.shared.add(e)
+	}
+	for c := range s.subs {
+		// Slow subscribers miss records, rather than hold up logging.
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+func (s *RingSink) Flush() {}
+
+// Entries returns the records that are kept, oldest first.
+func (s *RingSink) Entries() []RingEntry {
+	s.m.Lock()
+	res := s.shared.appendTo(nil)
+	for _, x := range s.levels {
+		res = x.appendTo(res)
+	}
+	s.m.Unlock()
+	if len(s.levels) > 0 {
+		sort.Slice(res, func(i, j int) bool { return res[i].Seq < res[j].Seq })
+	}
+	return res
+}
+
+// Subscribe returns a channel that receives the records logged from
+// then on, until cancel is called. Records that don't fit into the
+// buffer of the channel are skipped.
+func (s *RingSink) Subscribe(buffer int) (entries <-chan RingEntry, cancel func()) {
+	c := make(chan RingEntry, buffer)
+	s.m.Lock()
+	s.subs[c] = struct{}{}
+	s.m.Unlock()
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			s.m.Lock()
+			delete(s.subs, c)
+			s.m.Unlock()
+		})
+	}
+}
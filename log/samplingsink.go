@@ -0,0 +1,167 @@
+package log
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SamplingSinkOpts struct {
+	// Interval is the window over which records are counted.
+	Interval time.Duration
+	// First is the number of records with the same level and format
+	// that are let through as is in each interval.
+	First int
+	// Thereafter lets every Mth record through once First has been
+	// exceeded. Zero drops all of them.
+	Thereafter int
+}
+
+func DefaultSamplingSinkOpts() SamplingSinkOpts {
+	return SamplingSinkOpts{
+		Interval:   time.Second,
+		First:      100,
+		Thereafter: 100,
+	}
+}
+
+// SamplingSink limits the rate at which similar records, identified by
+// their level, format and call site, reach the inner sink. The call site
+// is only known to loggers with FlagSrcHint, so without it the records
+// of Logv and its variants, that have no format, are sampled together.
+// At the end of every interval, and on Flush, a summary record is logged
+// for each kind of record that had some of its occurrences suppressed.
+type SamplingSink struct {
+	inner Sink
+	opts  SamplingSinkOpts
+
+	m        sync.Mutex
+	counters map[samplingKey]*samplingCounter
+	timer    *time.Timer
+}
+
+type samplingKey struct {
+	level  Level
+	format string
+	file   string
+	line   int
+}
+
+type samplingCounter struct {
+	seen       int
+	suppressed int
+	logger     *Logger
+}
+
+func NewSamplingSink(inner Sink, opts *SamplingSinkOpts) *SamplingSink {
+	if opts == nil {
+		o := DefaultSamplingSinkOpts()
+		opts = &o
+	}
+	s := &SamplingSink{
+		inner:    inner,
+		opts:     *opts,
+		counters: make(map[samplingKey]*samplingCounter),
+	}
+	if s.opts.Interval <= 0 {
+		s.opts.Interval = DefaultSamplingSinkOpts().Interval
+	}
+	return s
+}
+
+func (s *SamplingSink) Log(r *Record) {
+	key := samplingKey{r.Meta.Level, r.Format, r.Meta.File, r.Meta.Line}
+
+	s.m.Lock()
+	if s.timer == nil {
+		var t *time.Timer
+		// Read by endInterval under s.m, so it's set by then.
+		t = time.AfterFunc(s.opts.Interval, func() { s.endInterval(&t) })
+		s.timer = t
+	}
+	c := s.counters[key]
+	if c == nil {
+		c = &samplingCounter{}
+		s.counters[key] = c
+	}
+	c.seen++
+	allow := c.seen <= s.opts.First ||
+		(s.opts.Thereafter > 0 && (c.seen-s.opts.First)%s.opts.Thereafter == 0)
+	if !allow {
+		c.suppressed++
+		c.logger = r.Meta.Logger
+	}
+	s.m.Unlock()
+
+	if allow {
+		s.inner.Log(r)
+	}
+}
+
+// Flush logs the summaries of the records suppressed so far in the
+// current interval, and flushes the inner sink.
+func (s *SamplingSink) Flush() {
+	s.m.Lock()
+	summaries := s.collectSummaries(time.Now())
+	s.m.Unlock()
+	for i := range summaries {
+		s.inner.Log(&summaries[i])
+	}
+	s.inner.Flush()
+}
+
+// endInterval logs the summaries of the interval, and starts over with
+// the counters of the next one.
+func (s *SamplingSink) endInterval(t **time.Timer) {
+	s.m.Lock()
+	// Only the current timer ends the interval, rather than one that
+	// was replaced.
+	if s.timer != *t {
+		s.m.Unlock()
+		return
+	}
+	s.timer = nil
+	summaries := s.collectSummaries(time.Now())
+	s.counters = make(map[samplingKey]*samplingCounter)
+	s.m.Unlock()
+	for i := range summaries {
+		s.inner.Log(&summaries[i])
+	}
+}
+
+func (s *SamplingSink) collectSummaries(now time.Time) []Record {
+	var res []Record
+	for k, c := range s.counters {
+		if c.suppressed == 0 {
+			continue
+		}
+		// Fields belong to just one of the suppressed records,
+		// so only the flags are carried over.
+		l := New(s.inner)
+		SetFlags(l, GetFlags(c.logger))
+		res = append(res, Record{
+			Meta: Metadata{
+				Logger: l,
+				Level:  k.level,
+				Time:   now,
+			},
+			Format: "sampling: suppressed %d occurrences of %q",
+			Args:   []interface{}{c.suppressed, k.label()},
+		})
+		c.suppressed = 0
+	}
+	return res
+}
+
+// label identifies the records of the key in its summary, by the format,
+// and the call site when it's known.
+func (k samplingKey) label() string {
+	if k.file == "" {
+		return k.format
+	}
+	site := k.file + ":" + strconv.Itoa(k.line)
+	if k.format == "" {
+		return site
+	}
+	return k.format + " at " + site
+}
@@ -0,0 +1,132 @@
+//go:build go1.21
+// +build go1.21
+
+// Package slogbridge connects log/slog with go-gluons/log, both ways, so
+// that libraries logging through slog end up in the same sinks.
+package slogbridge
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+// Handler is a slog.Handler that writes to a logger, the same way as its
+// Logw methods, with its fields, flags, filter, hooks and stack level.
+// Attrs become fields, with the names of the groups they're in joined by
+// dots as their prefix.
+type Handler struct {
+	l      *log.Logger
+	prefix string
+}
+
+func NewHandler(l *log.Logger) *Handler {
+	return &Handler{l: l}
+}
+
+func (h *Handler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.l.IsEnabled(LevelFromSlog(lvl))
+}
+
+func (h *Handler) Handle(ctx context.Context, sr slog.Record) error {
+	var fields []log.Field
+	if n := sr.NumAttrs(); n > 0 {
+		fields = make([]log.Field, 0, n)
+		sr.Attrs(func(a slog.Attr) bool {
+			fields = appendAttr(fields, h.prefix, a)
+			return true
+		})
+	}
+	h.l.LogwFrom(LevelFromSlog(sr.Level), sr.Time, sr.PC, sr.Message, fields...)
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	var fields []log.Field
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+	return &Handler{l: h.l.WithFields(fields), prefix: h.prefix}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{l: h.l, prefix: h.prefix + name + "."}
+}
+
+func appendAttr(dst []log.Field, prefix string, a slog.Attr) []log.Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		// Groups without a key are inlined.
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, x := range v.Group() {
+			dst = appendAttr(dst, prefix, x)
+		}
+		return dst
+	}
+	if a.Key == "" {
+		return dst
+	}
+	name := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindString:
+		return append(dst, log.String(name, v.String()))
+	case slog.KindInt64:
+		return append(dst, log.Int64(name, v.Int64()))
+	case slog.KindBool:
+		return append(dst, log.Bool(name, v.Bool()))
+	case slog.KindDuration:
+		return append(dst, log.Duration(name, v.Duration()))
+	case slog.KindTime:
+		return append(dst, log.Time(name, v.Time()))
+	}
+	x := v.Any()
+	if err, ok := x.(error); ok {
+		return append(dst, log.Field{Name: name, Kind: log.ErrorKind, Value: err})
+	}
+	return append(dst, log.Any(name, x))
+}
+
+// LevelFromSlog maps the slog level to the built in level it falls in.
+func LevelFromSlog(lvl slog.Level) log.Level {
+	switch {
+	case lvl >= slog.LevelError:
+		return log.ErrorLevel
+	case lvl >= slog.LevelWarn:
+		return log.WarnLevel
+	case lvl >= slog.LevelInfo:
+		return log.InfoLevel
+	case lvl >= slog.LevelDebug:
+		return log.DebugLevel
+	}
+	return log.TraceLevel
+}
+
+// LevelToSlog maps the level to slog, with fatal and panic above error,
+// and trace below debug. Custom levels take the slog level of the next
+// less severe built in level.
+func LevelToSlog(lvl log.Level) slog.Level {
+	switch {
+	case lvl <= log.FatalLevel:
+		return slog.LevelError + 4
+	case lvl <= log.PanicLevel:
+		return slog.LevelError + 2
+	case lvl <= log.ErrorLevel:
+		return slog.LevelError
+	case lvl <= log.WarnLevel:
+		return slog.LevelWarn
+	case lvl <= log.InfoLevel:
+		return slog.LevelInfo
+	case lvl <= log.DebugLevel:
+		return slog.LevelDebug
+	}
+	return slog.LevelDebug - 4
+}
@@ -0,0 +1,48 @@
+//go:build go1.21
+// +build go1.21
+
+package slogbridge
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+// Sink is a log.Sink that forwards the records to a slog.Handler. The
+// fields of the logger and the record are passed on as attrs, along with
+// the stack, as "stack", when it was captured. The call site is passed
+// on when the logger has FlagSrcHint.
+type Sink struct {
+	h slog.Handler
+}
+
+func NewSink(h slog.Handler) *Sink {
+	return &Sink{h: h}
+}
+
+func (s *Sink) Log(r *log.Record) {
+	ctx := context.Background()
+	lvl := LevelToSlog(r.Meta.Level)
+	if !s.h.Enabled(ctx, lvl) {
+		return
+	}
+	t := r.Meta.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	sr := slog.NewRecord(t, lvl, log.FormatMessage(r), r.Meta.PC)
+	for _, fields := range [...][]log.Field{log.GetFields(r.Meta.Logger), r.Fields} {
+		for _, f := range fields {
+			sr.AddAttrs(slog.Any(f.Name, f.Interface()))
+		}
+	}
+	if len(r.Stack) > 0 {
+		sr.AddAttrs(slog.String("stack", log.StackString(r.Stack)))
+	}
+	s.h.Handle(ctx, sr)
+}
+
+func (s *Sink) Flush() {}
@@ -0,0 +1,109 @@
+//go:build go1.21
+// +build go1.21
+
+package slogbridge_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/go-gluons/log/logtest"
+	"github.com/prasannavl/go-gluons/log/slogbridge"
+)
+
+func TestHandler(t *testing.T) {
+	l, s := logtest.NewLogger()
+	log.SetFilter(l, log.InfoLevelFilter)
+	sl := slog.New(slogbridge.NewHandler(l)).With("app", "x").WithGroup("req")
+
+	sl.Debug("hidden")
+	sl.Warn("slow", "ms", 1200, slog.Group("user", "id", 7), "err", errors.New("timeout"))
+
+	s.AssertNotLogged(t, logtest.Message("hidden"))
+	s.AssertLogged(t,
+		logtest.Level(log.WarnLevel),
+		logtest.Message("slow"),
+		logtest.Field("app", "x"),
+		logtest.Field("req.ms", 1200),
+		logtest.Field("req.user.id", 7),
+		logtest.Field("req.err", "timeout"))
+	if e := s.Entries()[0]; !strings.HasSuffix(e.File, "slogbridge_test.go") {
+		t.Errorf("unexpected source: %s:%d", e.File, e.Line)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := log.New(slogbridge.NewSink(h))
+	l.With("app", "x").Infow("started", log.Int("port", 8080))
+	l.Debug("hidden")
+	if got := buf.String(); got != "level=INFO msg=started app=x port=8080\n" {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+type recordSink struct {
+	records []log.Record
+}
+
+func (s *recordSink) Log(r *log.Record) {
+	x := *r
+	x.Fields = append([]log.Field(nil), r.Fields...)
+	x.Stack = append([]log.Frame(nil), r.Stack...)
+	s.records = append(s.records, x)
+}
+
+func (s *recordSink) Flush() {}
+
+func TestHandlerFlags(t *testing.T) {
+	s := &recordSink{}
+	l := log.New(s)
+	log.SetFlags(l, log.FlagStack)
+	log.SetStackLevel(l, log.ErrorLevel)
+	sl := slog.New(slogbridge.NewHandler(l))
+	sl.Warn("no stack")
+	sl.Error("with stack")
+
+	if len(s.records) != 2 {
+		t.Fatalf("unexpected records: %+v", s.records)
+	}
+	for _, r := range s.records {
+		if !r.Meta.Time.IsZero() || r.Meta.File != "" {
+			t.Errorf("expected no time or source without the flags, got %+v", r.Meta)
+		}
+	}
+	if len(s.records[0].Stack) != 0 {
+		t.Errorf("expected no stack below the stack level, got %v", s.records[0].Stack)
+	}
+	stack := s.records[1].Stack
+	if len(stack) == 0 || !strings.HasSuffix(stack[0].Function, "slogbridge_test.TestHandlerFlags") {
+		t.Errorf("expected the stack to start at the caller, got %v", stack)
+	}
+}
+
+func TestSinkSourceAndStack(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{AddSource: true})
+	l := log.New(slogbridge.NewSink(h))
+	log.SetFlags(l, log.FlagSrcHint|log.FlagStack)
+	l.Error("failed")
+	out := buf.String()
+	if !strings.Contains(out, "slogbridge_test.go:") {
+		t.Errorf("expected the source of the call, got %q", out)
+	}
+	if !strings.Contains(out, `stack="github.com/prasannavl/go-gluons/log/slogbridge_test.TestSinkSourceAndStack `) {
+		t.Errorf("expected the stack, got %q", out)
+	}
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"path"
+	"runtime/debug"
+	"strings"
+)
+
+type SrcPathMode int
+
+const (
+	// SrcPathFull leaves the path as it was at build time.
+	SrcPathFull SrcPathMode = iota
+	// SrcPathModule makes the path relative to the main module, like
+	// "http/middleware/logger.go". Files of other modules keep their
+	// import path, like "github.com/pkg/errors/errors.go".
+	SrcPathModule
+	// SrcPathPackage keeps just the package directory and the file,
+	// like "middleware/logger.go".
+	SrcPathPackage
+)
+
+var mainModulePath = func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path
+	}
+	return ""
+}()
+
+// TrimSrcPath trims the file path of a source hint. The function is the
+// qualified name of the function in it, from which the import path of
+// the package is derived for SrcPathModule.
+func TrimSrcPath(mode SrcPathMode, file string, function string) string {
+	switch mode {
+	case SrcPathModule:
+		pkg := funcPackagePath(function)
+		if pkg == "" {
+			return file
+		}
+		if mod := mainModulePath; mod != "" && mod != "command-line-arguments" {
+			if pkg == mod {
+				return path.Base(file)
+			}
+			if strings.HasPrefix(pkg, mod+"/") {
+				return pkg[len(mod)+1:] + "/" + path.Base(file)
+			}
+		}
+		return pkg + "/" + path.Base(file)
+	case SrcPathPackage:
+		dir, name := path.Split(file)
+		if dir == "" {
+			return file
+		}
+		return path.Base(dir) + "/" + name
+	}
+	return file
+}
+
+// funcPackagePath returns the import path from a qualified function name,
+// like "github.com/prasannavl/go-gluons/log" for
+// "github.com/prasannavl/go-gluons/log.(*Logger).Info".
+func funcPackagePath(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[slash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	// External test packages live in the directory of the package.
+	return strings.TrimSuffix(function[:slash+1+dot], "_test")
+}
+
+// WithSrcPathMode wraps a formatter, so that the file of the source hint
+// is trimmed with the mode before the record is rendered.
+func WithSrcPathMode(formatter func(*Record) string, mode SrcPathMode) func(*Record) string {
+	if mode == SrcPathFull {
+		return formatter
+	}
+	return func(r *Record) string {
+		if r.Meta.File == "" {
+			return formatter(r)
+		}
+		x := *r
+		x.Meta.File = TrimSrcPath(mode, r.Meta.File, r.Meta.Function)
+		return formatter(&x)
+	}
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// Frame is a single call of the stack captured with FlagStack.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+const maxStackDepth = 32
+
+// appendStack appends the stack of the calling goroutine, skipping skip
+// frames, where 0 is the caller of appendStack. The frames of the runtime
+// that start the goroutine are left out.
+func appendStack(dst []Frame, skip int) []Frame {
+	return appendStackFrom(dst, skip+1, 0)
+}
+
+// appendStackFrom is like appendStack, except that when pc is on the
+// stack, the frames above it are left out as well.
+func appendStackFrom(dst []Frame, skip int, pc uintptr) []Frame {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	start := 0
+	for i := 0; pc != 0 && i < n; i++ {
+		if pcs[i] == pc {
+			start = i
+			break
+		}
+	}
+	frames := runtime.CallersFrames(pcs[start:n])
+	for {
+		fr, more := frames.Next()
+		if fr.Function == "runtime.main" || fr.Function == "runtime.goexit" {
+			break
+		}
+		dst = append(dst, Frame{fr.Function, fr.File, fr.Line})
+		if !more {
+			break
+		}
+	}
+	return dst
+}
+
+// StackString renders the stack one call per line, as function
+// followed by file:line.
+func StackString(stack []Frame) string {
+	var buf bytes.Buffer
+	for i, fr := range stack {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(fr.Function + " " + fr.File + ":" + strconv.Itoa(fr.Line))
+	}
+	return buf.String()
+}
+
+// writeStackLines writes the stack below the record line, in the same
+// layout as the goroutine traces of the runtime.
+func writeStackLines(buf *bytes.Buffer, stack []Frame) {
+	for _, fr := range stack {
+		buf.WriteString("\r\n\t" + fr.Function)
+		buf.WriteString("\r\n\t\t" + fr.File + ":" + strconv.Itoa(fr.Line))
+	}
+}
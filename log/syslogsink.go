@@ -0,0 +1,245 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SyslogSinkOpts struct {
+	// Network is one of "unixgram", "unix", "udp" or "tcp". If empty,
+	// the local syslog socket is used.
+	Network  string
+	Address  string
+	Facility int
+	Hostname string
+	AppName  string
+	// StructuredDataID is the SD-ID of the element that holds the
+	// fields of the record.
+	StructuredDataID string
+	// Formatter renders the MSG part. Defaults to FormatMessage.
+	Formatter func(*Record) string
+}
+
+func DefaultSyslogSinkOpts() SyslogSinkOpts {
+	hostname, _ := os.Hostname()
+	return SyslogSinkOpts{
+		Facility:         SyslogFacilityUser,
+		Hostname:         hostname,
+		AppName:          filepath.Base(os.Args[0]),
+		StructuredDataID: "fields@32473",
+		Formatter:        FormatMessage,
+	}
+}
+
+const (
+	SyslogFacilityKern   = 0
+	SyslogFacilityUser   = 1
+	SyslogFacilityDaemon = 3
+	SyslogFacilityLocal0 = 16
+)
+
+var localSyslogAddrs = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogSink writes records as RFC 5424 messages. Fields are sent as
+// the params of a single structured data element. Messages over tcp
+// and unix stream sockets are framed with octet-counting (RFC 6587).
+type SyslogSink struct {
+	opts SyslogSinkOpts
+	m    sync.Mutex
+	conn net.Conn
+	buf  bytes.Buffer
+}
+
+func NewSyslogSink(opts *SyslogSinkOpts) (*SyslogSink, error) {
+	if opts == nil {
+		o := DefaultSyslogSinkOpts()
+		opts = &o
+	}
+	s := &SyslogSink{opts: *opts}
+	if s.opts.Formatter == nil {
+		s.opts.Formatter = FormatMessage
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.opts.Network != "" {
+		c, err := net.Dial(s.opts.Network, s.opts.Address)
+		if err != nil {
+			return err
+		}
+		s.conn = c
+		return nil
+	}
+	addrs := localSyslogAddrs
+	if s.opts.Address != "" {
+		addrs = []string{s.opts.Address}
+	}
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			if c, err := net.Dial(network, addr); err == nil {
+				s.opts.Network = network
+				s.opts.Address = addr
+				s.conn = c
+				return nil
+			}
+		}
+	}
+	return errors.New("syslog: no local syslog socket found")
+}
+
+func (s *SyslogSink) Log(r *Record) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.buf.Reset()
+	s.writeMessage(&s.buf, r)
+	msg := s.buf.Bytes()
+	if s.opts.Network == "tcp" || s.opts.Network == "unix" {
+		msg = append(strconv.AppendInt(nil, int64(len(msg)), 10), ' ')
+		msg = append(msg, s.buf.Bytes()...)
+	}
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil {
+			return
+		}
+	}
+	// Retry once on a new connection, in case the daemon restarted.
+	if err := s.connect(); err == nil {
+		s.conn.Write(msg)
+	}
+}
+
+func (s *SyslogSink) Flush() {}
+
+func (s *SyslogSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) writeMessage(buf *bytes.Buffer, r *Record) {
+	pri := s.opts.Facility*8 + SyslogSeverity(r.Meta.Level)
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(pri))
+	buf.WriteString(">1 ")
+	t := r.Meta.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(s.opts.Hostname, 255))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(s.opts.AppName, 48))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(os.Getpid()))
+	buf.WriteString(" - ")
+	s.writeStructuredData(buf, r)
+	buf.WriteByte(' ')
+	buf.WriteString(s.opts.Formatter(r))
+}
+
+func (s *SyslogSink) writeStructuredData(buf *bytes.Buffer, r *Record) {
+	hasFields := len(GetFields(r.Meta.Logger)) > 0 || len(r.Fields) > 0
+	if !hasFields && r.Meta.File == "" {
+		buf.WriteByte('-')
+		return
+	}
+	buf.WriteByte('[')
+	buf.WriteString(s.opts.StructuredDataID)
+	var scratch []byte
+	writeParam := func(name string, value []byte) {
+		buf.WriteByte(' ')
+		buf.WriteString(syslogParamName(name))
+		buf.WriteString(`="`)
+		for _, c := range value {
+			if c == '"' || c == '\\' || c == ']' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(c)
+		}
+		buf.WriteByte('"')
+	}
+	if r.Meta.File != "" {
+		writeParam("file", []byte(r.Meta.File))
+		writeParam("line", strconv.AppendInt(scratch[:0], int64(r.Meta.Line), 10))
+	}
+	for _, fields := range [...][]Field{GetFields(r.Meta.Logger), r.Fields} {
+		for _, x := range fields {
+			scratch = x.AppendText(scratch[:0])
+			writeParam(x.Name, scratch)
+		}
+	}
+	buf.WriteByte(']')
+}
+
+// SyslogSeverity maps the level to its syslog severity. Custom levels
+// in between WarnLevel and InfoLevel are notices, and the others share
+// the severity of the nearby built in levels.
+func SyslogSeverity(lvl Level) int {
+	switch {
+	case lvl == DisabledLevel:
+		return 5
+	case lvl < ErrorLevel:
+		return 2
+	case lvl == ErrorLevel:
+		return 3
+	case lvl <= WarnLevel:
+		return 4
+	case lvl < InfoLevel:
+		return 5
+	case lvl == InfoLevel:
+		return 6
+	}
+	return 7
+}
+
+func syslogHeaderField(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	return string(b)
+}
+
+func syslogParamName(s string) string {
+	if s == "" {
+		return "_"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	return string(b)
+}
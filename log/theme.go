@@ -0,0 +1,118 @@
+package log
+
+import (
+	"sync/atomic"
+
+	"github.com/prasannavl/go-gluons/ansicode"
+)
+
+// Theme is the set of colors of the colored formatters, as ANSI codes.
+// Custom levels are rendered with the color they're registered with.
+type Theme struct {
+	Time  string
+	Fatal string
+	Panic string
+	Error string
+	Warn  string
+	Info  string
+	Debug string
+	Trace string
+	// Fields are the colors the names of the fields are spread over,
+	// by a hash of the name, so that a name always has the same color.
+	Fields []string
+	Stack  string
+}
+
+func DefaultTheme() Theme {
+	return Theme{
+		Time:  ansicode.BlackBright,
+		Fatal: ansicode.RedBg + ansicode.WhiteBright,
+		Panic: ansicode.MagentaBright,
+		Error: ansicode.RedBright,
+		Warn:  ansicode.YellowBright,
+		Info:  ansicode.Blue,
+		Debug: ansicode.White,
+		Trace: ansicode.BlackBright,
+		Fields: []string{
+			ansicode.BlackBright,
+			ansicode.Cyan,
+			ansicode.Green,
+			ansicode.Magenta,
+		},
+		Stack: ansicode.BlackBright,
+	}
+}
+
+var theme atomic.Value // *Theme
+
+func init() {
+	t := DefaultTheme()
+	theme.Store(&t)
+}
+
+// SetTheme sets the theme of DefaultColorTextFormatterForHuman,
+// LogLevelColoredMsg and HashColoredText. Nil restores the default.
+func SetTheme(t *Theme) {
+	if t == nil {
+		x := DefaultTheme()
+		t = &x
+	}
+	theme.Store(t)
+}
+
+func GetTheme() *Theme {
+	return theme.Load().(*Theme)
+}
+
+func (t *Theme) LevelColor(lvl Level) string {
+	switch lvl {
+	case FatalLevel:
+		return t.Fatal
+	case PanicLevel:
+		return t.Panic
+	case ErrorLevel:
+		return t.Error
+	case WarnLevel:
+		return t.Warn
+	case InfoLevel:
+		return t.Info
+	case DebugLevel:
+		return t.Debug
+	case TraceLevel:
+		return t.Trace
+	}
+	if x, ok := lookupCustomLevel(lvl); ok && x.color != "" {
+		return x.color
+	}
+	return t.Trace
+}
+
+func (t *Theme) FieldColor(name string) string {
+	if len(t.Fields) == 0 {
+		return ""
+	}
+	const maxIterations = 10
+	l := len(name)
+	if l > 10 {
+		l = 10
+	}
+	for i, x := range name {
+		if i > maxIterations {
+			break
+		}
+		l += int(x)
+	}
+	index := l % len(t.Fields)
+	if index < 0 {
+		index = 0
+	}
+	return t.Fields[index]
+}
+
+// colored wraps the text in the color, unless the color is empty.
+func colored(color string, text string) string {
+	if color == "" {
+		return text
+	}
+	return color + text + ansicode.Reset
+}
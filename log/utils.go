@@ -30,8 +30,14 @@ func TraceLevelFilter(lvl Level) bool {
 	return AllLevelsFilter(lvl)
 }
 
+// LogLevelFromString returns an invalid level for unknown names, as
+// reported by IsValidLevel. ParseLevel returns an error instead.
 func LogLevelFromString(level string) Level {
 	switch level {
+	case "fatal":
+		return FatalLevel
+	case "panic":
+		return PanicLevel
 	case "error":
 		return ErrorLevel
 	case "warn":
@@ -45,12 +51,19 @@ func LogLevelFromString(level string) Level {
 	case "off", "disabled":
 		return DisabledLevel
 	default:
+		if lvl, ok := lookupCustomLevelName(level); ok {
+			return lvl
+		}
 		return Level(^uint(0))
 	}
 }
 
 func LogLevelString(lvl Level) string {
 	switch lvl {
+	case FatalLevel:
+		return "fatal"
+	case PanicLevel:
+		return "panic"
 	case ErrorLevel:
 		return "error"
 	case WarnLevel:
@@ -64,6 +77,9 @@ func LogLevelString(lvl Level) string {
 	case DisabledLevel:
 		return "off"
 	}
+	if x, ok := lookupCustomLevel(lvl); ok {
+		return x.name
+	}
 	return "msg"
 }
 
@@ -82,17 +98,19 @@ func LogFilterForLevel(lvl Level) func(Level) bool {
 	case DisabledLevel:
 		return DisabledFilter
 	default:
+		if IsValidLevel(lvl) {
+			return func(x Level) bool { return x <= lvl }
+		}
 		return InfoLevelFilter
 	}
 }
 
 func IsValidLevel(lvl Level) bool {
-	switch lvl {
-	case DisabledLevel, ErrorLevel, WarnLevel, InfoLevel, DebugLevel, TraceLevel:
+	if isBuiltinLevel(lvl) {
 		return true
-	default:
-		return false
 	}
+	_, ok := lookupCustomLevel(lvl)
+	return ok
 }
 
 func PaddedString(s string, width int) string {
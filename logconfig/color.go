@@ -0,0 +1,35 @@
+package logconfig
+
+import (
+	"io"
+	"os"
+)
+
+const (
+	EnvNoColor    = "NO_COLOR"
+	EnvForceColor = "FORCE_COLOR"
+)
+
+// colorSupported reports whether colors can be written to w, when they're
+// enabled: w has to be a terminal, unless FORCE_COLOR is set. NO_COLOR,
+// or a TERM of "dumb", turn them off regardless.
+func colorSupported(w io.Writer) bool {
+	if os.Getenv(EnvNoColor) != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	switch os.Getenv(EnvForceColor) {
+	case "", "0", "false":
+	default:
+		return true
+	}
+	return isTerminal(w)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
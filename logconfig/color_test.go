@@ -0,0 +1,61 @@
+package logconfig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/ansicode"
+	"github.com/prasannavl/go-gluons/log"
+)
+
+func TestColorSupported(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	t.Setenv(EnvNoColor, "")
+	t.Setenv(EnvForceColor, "")
+
+	var buf bytes.Buffer
+	if colorSupported(&buf) {
+		t.Error("expected no colors for a buffer")
+	}
+	f, err := ioutil.TempFile(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if colorSupported(f) {
+		t.Error("expected no colors for a regular file")
+	}
+
+	t.Setenv(EnvForceColor, "1")
+	if !colorSupported(&buf) {
+		t.Error("expected FORCE_COLOR to color a buffer")
+	}
+	t.Setenv(EnvNoColor, "1")
+	if colorSupported(&buf) {
+		t.Error("expected NO_COLOR to take precedence")
+	}
+	t.Setenv(EnvNoColor, "")
+	t.Setenv("TERM", "dumb")
+	if colorSupported(&buf) {
+		t.Error("expected no colors for a dumb terminal")
+	}
+
+	opts := DefaultOutputOptions()
+	opts.Humanize = true
+	opts.EnableColor = true
+	t.Setenv("TERM", "xterm")
+	t.Setenv(EnvForceColor, "")
+	r := &log.Record{Meta: log.Metadata{Logger: log.New(log.NopSink{}), Level: log.InfoLevel}, Format: "hi"}
+	if s := formatterFromOptions(&opts, f)(r); strings.Contains(s, "\x1b") {
+		t.Errorf("unexpected colors in %q", s)
+	}
+	t.Setenv(EnvForceColor, "1")
+	theme := log.DefaultTheme()
+	theme.Info = ansicode.Fg256(208)
+	opts.Theme = &theme
+	if s := formatterFromOptions(&opts, f)(r); !strings.Contains(s, "\x1b[38;5;208minfo") {
+		t.Errorf("expected the color of the theme in %q", s)
+	}
+}
@@ -1,11 +1,18 @@
 package logconfig
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"io"
+	"io/ioutil"
 	stdlog "log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/prasannavl/go-gluons/log"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -23,9 +30,57 @@ type Options struct {
 	MaxBackups      int
 	MaxAge          int // days
 	CompressBackups bool
-	Humanize        bool
-	EnableColor     bool
-	StdLogLevel     log.Level
+	// RotationSchedule rotates rolling files hourly or daily, in
+	// addition to rotating them by size.
+	RotationSchedule string
+	// RotateOnSignal rotates all rolling files on SIGUSR1, to cooperate
+	// with an external logrotate.
+	RotateOnSignal bool
+	// PostRotate is called with the name of each rotated out file.
+	// It's only supported for scheduled or patterned rolling files.
+	PostRotate func(filename string)
+	Format     string
+	Humanize   bool
+	// EnableColor colors the humanized output when it's written to a
+	// terminal, unless NO_COLOR is set or TERM is "dumb". FORCE_COLOR
+	// colors it even when it's not a terminal.
+	EnableColor bool
+	// Theme is the colors of the humanized output, in place of the
+	// default theme of the log package.
+	Theme *log.Theme
+	// PrettyStructs renders the struct, map and slice values of the
+	// fields of the humanized output as indented JSON.
+	PrettyStructs bool
+	// SrcPathMode trims the file paths of the source hints.
+	SrcPathMode log.SrcPathMode
+	// TLSCAFile, TLSCertFile and TLSKeyFile are the PEM files of the CAs
+	// that the server of tls:// targets is verified with, in place of
+	// the ones of the system, and of the client certificate.
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSInsecure skips the verification of the server.
+	TLSInsecure bool
+	StdLogLevel log.Level
+	// StackLevel captures the stack for records of this level or more
+	// severe. Stacks aren't captured when it's disabled.
+	StackLevel log.Level
+	// RedactFields and RedactPatterns mask the values of the fields of
+	// these names, and the matches of these regular expressions in the
+	// messages, in all the outputs.
+	RedactFields   []string
+	RedactPatterns []string
+	// SlogDefault makes the default slog logger write to the logger as
+	// well, which changes it for the whole process, so it's off unless
+	// asked for. It requires Go 1.21, and is ignored on older versions.
+	SlogDefault bool
+	// Hooks run on every record of the logger, before it reaches any of
+	// the outputs.
+	Hooks []log.Hook
+
+	// Outputs, when set, take the place of the single output described
+	// by the LogFile, VerbosityLevel, format and rolling options above.
+	Outputs []OutputOptions
 }
 
 func DefaultOptions() Options {
@@ -40,40 +95,87 @@ func DefaultOptions() Options {
 		MaxBackups:       2,
 		MaxAge:           28,
 		CompressBackups:  true,
+		Format:           Formats.Text,
 		Humanize:         true,
 		EnableColor:      true,
 		StdLogLevel:      log.TraceLevel,
 	}
 }
 
+// Init is like InitE, except that it exits the process on errors.
 func Init(opts *Options, result *LogInitResult) {
-	result.Enabled = false
-	logFile := opts.LogFile
-	if logFile == CommonTargets.TargetNull {
-		return
+	res, err := InitE(opts)
+	if err != nil {
+		stdlog.Fatalf("error: logger => %s", err.Error())
 	}
-	level := LogLevelFromVerbosityLevel(opts.VerbosityLevel)
-	if level == 0 {
-		return
+	*result = res
+	for _, o := range res.Outputs {
+		for _, e := range o.Errors {
+			log.Warnf("logger: %s, falling back to %s", e.Error(), o.Filename)
+		}
 	}
-	s, name := mustCreateWriteStream(opts)
-	var formatter func(r *log.Record) string
+}
 
-	if opts.Humanize {
-		if opts.EnableColor {
-			formatter = log.DefaultColorTextFormatterForHuman
-		} else {
-			formatter = log.DefaultTextFormatterForHuman
+// InitE sets up the global logger and the std logger. Log files that
+// cannot be opened fall back to the same name with the PID appended,
+// and then to stderr. The failures that led to the fallback are reported
+// in the output results, rather than as an error.
+func InitE(opts *Options) (result LogInitResult, err error) {
+	var redactPatterns []*regexp.Regexp
+	for _, p := range opts.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return LogInitResult{}, &InitError{Kind: ErrKindRedaction, Err: err}
 		}
-	} else {
-		formatter = log.DefaultTextFormatter
+		redactPatterns = append(redactPatterns, re)
+	}
+
+	var sinks []log.Sink
+	var maxLevel log.Level
+	outputs := outputsFromOptions(opts)
+	for i := range outputs {
+		out := &outputs[i]
+		if out.LogFile == CommonTargets.TargetNull {
+			continue
+		}
+		level := LogLevelFromVerbosityLevel(out.VerbosityLevel)
+		if level == 0 {
+			continue
+		}
+		if err = validateOutput(out); err != nil {
+			result.Close()
+			return LogInitResult{}, err
+		}
+		sink, res := createOutputSink(opts, out)
+		if len(outputs) > 1 {
+			sink = &log.LeveledSink{
+				MaxLevel: level,
+				Inner:    sink,
+			}
+		}
+		sinks = append(sinks, sink)
+		result.Outputs = append(result.Outputs, res)
+		if level > maxLevel {
+			maxLevel = level
+		}
+	}
+	if len(sinks) == 0 {
+		return result, nil
 	}
 
 	var sink log.Sink
+	if len(sinks) == 1 {
+		sink = sinks[0]
+	} else {
+		sink = log.CreateMultiSink(sinks...)
+	}
 
-	sink = &log.StreamSink{
-		Formatter: formatter,
-		Stream:    s,
+	if len(opts.RedactFields) > 0 || len(redactPatterns) > 0 {
+		sink = log.NewRedactingSink(sink, &log.RedactingSinkOpts{
+			Fields:   opts.RedactFields,
+			Patterns: redactPatterns,
+			Mask:     log.DefaultRedactingSinkOpts().Mask,
+		})
 	}
 
 	if opts.LoggerMutex {
@@ -82,27 +184,265 @@ func Init(opts *Options, result *LogInitResult) {
 		}
 	}
 
+	if opts.RotateOnSignal {
+		result.stopRotateOnSignal = rotateOnSignal(result.Outputs)
+	}
+
 	l := log.New(sink)
-	log.SetFilter(l, log.LogFilterForLevel(level))
+	if len(opts.Hooks) > 0 {
+		l = l.WithHooks(opts.Hooks...)
+	}
+	log.SetFilterLevel(l, maxLevel)
+	if opts.StackLevel != log.DisabledLevel {
+		log.SetFlags(l, log.GetFlags(l)|log.FlagStack)
+		log.SetStackLevel(l, opts.StackLevel)
+	}
 	log.SetLogger(l)
+	if opts.SlogDefault {
+		// Done first, since it also redirects the std logger.
+		setSlogDefault(l)
+	}
 	stdWriter := log.NewLogWriter(l, opts.StdLogLevel, "std: ")
 	stdlog.SetOutput(stdWriter)
 
 	result.Enabled = true
-	result.Filename = name
+	result.Filename = result.Outputs[0].Filename
 	result.Logger = l
-	result.Writer = s
+	result.Writer = result.Outputs[0].Writer
 	result.StdWriter = stdWriter
 	result.StdLogger = stdlog.New(stdWriter, "", 0)
+	return result, nil
+}
+
+func validateOutput(out *OutputOptions) error {
+	if !out.Rolling {
+		return nil
+	}
+	if out.MaxSize < 0 || out.MaxBackups < 0 || out.MaxAge < 0 {
+		return &InitError{
+			Kind: ErrKindRotation,
+			Path: out.LogFile,
+			Err:  errors.New("max size, backups and age cannot be negative"),
+		}
+	}
+	switch out.RotationSchedule {
+	case RotationSchedules.None, RotationSchedules.Hourly, RotationSchedules.Daily:
+	default:
+		return &InitError{
+			Kind: ErrKindRotation,
+			Path: out.LogFile,
+			Err:  errors.New("unknown rotation schedule " + strconv.Quote(out.RotationSchedule)),
+		}
+	}
+	return nil
+}
+
+func createOutputSink(opts *Options, out *OutputOptions) (log.Sink, OutputResult) {
+	var sink log.Sink
+	var err error
+	switch out.LogFile {
+	case CommonTargets.TargetSyslog:
+		var s *log.SyslogSink
+		if s, err = log.NewSyslogSink(nil); err == nil {
+			sink = s
+		}
+	case CommonTargets.TargetJournald:
+		var s *log.JournaldSink
+		if s, err = log.NewJournaldSink(nil); err == nil {
+			sink = s
+		}
+	default:
+		if network, address, ok := parseNetworkTarget(out.LogFile); ok {
+			nopts := log.DefaultNetworkSinkOpts()
+			nopts.Network = network
+			nopts.Address = address
+			nopts.Formatter = formatterFromOptions(out, nil)
+			if strings.HasPrefix(out.LogFile, "tls://") {
+				if nopts.TLSConfig, err = tlsConfigFromOptions(out); err != nil {
+					break
+				}
+			}
+			sink = log.NewNetworkSink(&nopts)
+			break
+		}
+		res := createWriteStream(opts, out)
+		sink = &log.StreamSink{
+			Formatter: formatterFromOptions(out, res.Writer),
+			Stream:    res.Writer,
+		}
+		return sink, res
+	}
+	res := OutputResult{
+		Requested: out.LogFile,
+		Filename:  out.LogFile,
+	}
+	if err != nil {
+		res.Errors = append(res.Errors, &InitError{Kind: ErrKindSink, Path: out.LogFile, Err: err})
+		res.Filename = CommonTargets.TargetStdErr
+		res.Writer = os.Stderr
+		sink = &log.StreamSink{
+			Formatter: formatterFromOptions(out, os.Stderr),
+			Stream:    os.Stderr,
+		}
+	} else {
+		res.closer = sink.(io.Closer)
+	}
+	return sink, res
+}
+
+// parseNetworkTarget parses the targets of the form tcp://host:port,
+// tls://host:port, udp://host:port, unix:///path or unixgram:///path.
+func parseNetworkTarget(target string) (network, address string, ok bool) {
+	i := strings.Index(target, "://")
+	if i < 0 {
+		return "", "", false
+	}
+	network, address = target[:i], target[i+3:]
+	if address == "" {
+		return "", "", false
+	}
+	switch network {
+	case "tls":
+		network = "tcp"
+	case "tcp", "udp", "unix", "unixgram":
+	default:
+		return "", "", false
+	}
+	return network, address, true
+}
+
+// tlsConfigFromOptions returns the config of the tls:// targets.
+func tlsConfigFromOptions(out *OutputOptions) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: out.TLSInsecure}
+	if out.TLSCAFile != "" {
+		data, err := ioutil.ReadFile(out.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificates in " + out.TLSCAFile)
+		}
+	}
+	if out.TLSCertFile != "" || out.TLSKeyFile != "" {
+		c, err := tls.LoadX509KeyPair(out.TLSCertFile, out.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{c}
+	}
+	return config, nil
+}
+
+// formatterFromOptions returns the formatter of the output, that writes
+// to w. Colors are only used when w supports them.
+func formatterFromOptions(opts *OutputOptions, w io.Writer) func(r *log.Record) string {
+	var formatter func(r *log.Record) string
+	switch {
+	case opts.Format == Formats.JSON:
+		formatter = log.JSONFormatter
+	case opts.Format == Formats.Logfmt:
+		formatter = log.LogfmtFormatter
+	case opts.Humanize:
+		formatter = log.NewHumanFormatter(&log.HumanFormatterOpts{
+			Color:         opts.EnableColor && colorSupported(w),
+			Theme:         opts.Theme,
+			PrettyStructs: opts.PrettyStructs,
+		})
+	default:
+		formatter = log.DefaultTextFormatter
+	}
+	return log.WithSrcPathMode(formatter, opts.SrcPathMode)
 }
 
 type LogInitResult struct {
-	Enabled   bool
+	Enabled bool
+	// Filename and Writer are that of the first output.
 	Filename  string
 	Writer    io.Writer
+	Outputs   []OutputResult
 	Logger    *log.Logger
 	StdWriter *log.LogWriter
 	StdLogger *stdlog.Logger
+
+	stopRotateOnSignal func()
+}
+
+type OutputResult struct {
+	// Requested is the target as given in the options, while Filename
+	// is the one that's actually in use.
+	Requested string
+	Filename  string
+	Writer    io.Writer
+	// Errors are the failures that led to Filename being used instead
+	// of the requested target.
+	Errors []error
+	closer io.Closer
+}
+
+type rotator interface {
+	Rotate() error
+}
+
+func rotateOnSignal(outputs []OutputResult) (stop func()) {
+	var rotators []rotator
+	for _, o := range outputs {
+		if r, ok := o.Writer.(rotator); ok {
+			rotators = append(rotators, r)
+		}
+	}
+	if len(rotators) == 0 || len(rotateSignals) == 0 {
+		return nil
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, rotateSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				for _, r := range rotators {
+					if err := r.Rotate(); err != nil {
+						log.Errorf("logger: rotate: %v", err)
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+func (o *OutputResult) IsFallback() bool {
+	return o.Filename != o.Requested
+}
+
+// Close flushes the logger, and closes the files and connections
+// opened for it. Standard streams are left open.
+func (r *LogInitResult) Close() error {
+	if r.stopRotateOnSignal != nil {
+		r.stopRotateOnSignal()
+		r.stopRotateOnSignal = nil
+	}
+	if r.Logger != nil {
+		r.Logger.Flush()
+	}
+	var err error
+	for i := range r.Outputs {
+		o := &r.Outputs[i]
+		if o.closer == nil {
+			continue
+		}
+		if e := o.closer.Close(); e != nil && err == nil {
+			err = e
+		}
+		o.closer = nil
+	}
+	return err
 }
 
 func LogLevelFromVerbosityLevel(vLevel int) log.Level {
@@ -121,59 +461,82 @@ func LogLevelFromVerbosityLevel(vLevel int) log.Level {
 	return log.TraceLevel
 }
 
-func mustCreateWriteStream(opts *Options) (w io.Writer, filename string) {
-	var err error
-	logFile := opts.LogFile
-	const errFormat = "error: logger => %s"
+func createWriteStream(opts *Options, out *OutputOptions) OutputResult {
+	logFile := out.LogFile
 	if logFile == "" {
-		logFile, err = checkedLogFileName(filepath.Clean(opts.FallbackDir + "/" + opts.FallbackFileName))
-		if err != nil {
-			stdlog.Fatalf(errFormat, err.Error())
-		}
+		logFile = filepath.Clean(opts.FallbackDir + "/" + opts.FallbackFileName)
 	}
+	res := OutputResult{Requested: logFile, Filename: logFile}
 	switch logFile {
 	case CommonTargets.TargetStdOut:
-		return os.Stdout, logFile
+		res.Writer = os.Stdout
+		return res
 	case CommonTargets.TargetStdErr:
-		return os.Stderr, logFile
-	default:
-		if err := ensureFileParentDir(logFile); err != nil {
-			stdlog.Fatalf(errFormat, err.Error())
+		res.Writer = os.Stderr
+		return res
+	}
+	for _, name := range []string{logFile, alternateFileName(logFile)} {
+		w, closer, err := openLogFile(name, out, opts.PostRotate)
+		if err == nil {
+			res.Filename = name
+			res.Writer = w
+			res.closer = closer
+			return res
 		}
-		if logFile, err = checkedLogFileName(logFile); err != nil {
-			stdlog.Fatalf(errFormat, err.Error())
+		res.Errors = append(res.Errors, err)
+	}
+	res.Filename = CommonTargets.TargetStdErr
+	res.Writer = os.Stderr
+	return res
+}
+
+func openLogFile(logFile string, out *OutputOptions, postRotate func(string)) (io.Writer, io.Closer, error) {
+	if out.Rolling && (out.RotationSchedule != RotationSchedules.None || hasFilePattern(logFile)) {
+		f := &RotatingFile{
+			Filename:   logFile,
+			Schedule:   out.RotationSchedule,
+			MaxSize:    out.MaxSize,
+			MaxBackups: out.MaxBackups,
+			MaxAge:     out.MaxAge,
+			Compress:   out.CompressBackups,
+			PostRotate: postRotate,
 		}
-		if !opts.Rolling {
-			fd, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0644))
-			if err != nil {
-				stdlog.Println(errFormat, err.Error())
+		if err := f.Rotate(); err != nil {
+			kind := ErrKindOpen
+			if os.IsPermission(err) {
+				kind = ErrKindPermission
 			}
-			return fd, logFile
+			return nil, nil, &InitError{Kind: kind, Path: logFile, Err: err}
 		}
-		return &lumberjack.Logger{
-			Filename:   logFile,
-			MaxSize:    opts.MaxSize,
-			MaxBackups: opts.MaxBackups,
-			MaxAge:     opts.MaxAge,
-			Compress:   opts.CompressBackups,
-		}, logFile
+		return f, f, nil
 	}
-}
-
-// This method tries to touch the file, and if not,
-// try again once with the PID appended. If that
-// fails too - error.
-func checkedLogFileName(logFile string) (string, error) {
-	filename := logFile
-	if err := touchFile(filename); err != nil {
-		stdlog.Printf("warn: logger => %s", err.Error())
-		filename = alternateFileName(filename)
-		if e := touchFile(filename); e != nil {
-			// Return the old error
-			return "", err
+	if err := ensureFileParentDir(logFile); err != nil {
+		return nil, nil, &InitError{Kind: ErrKindCreateDir, Path: logFile, Err: err}
+	}
+	fd, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0644))
+	if err != nil {
+		kind := ErrKindOpen
+		if os.IsPermission(err) {
+			kind = ErrKindPermission
 		}
+		return nil, nil, &InitError{Kind: kind, Path: logFile, Err: err}
 	}
-	return filename, nil
+	if !out.Rolling {
+		return fd, fd, nil
+	}
+	// Lumberjack opens the file lazily, so it's only
+	// checked to be writable here.
+	if err = fd.Close(); err != nil {
+		return nil, nil, &InitError{Kind: ErrKindOpen, Path: logFile, Err: err}
+	}
+	l := &lumberjack.Logger{
+		Filename:   logFile,
+		MaxSize:    out.MaxSize,
+		MaxBackups: out.MaxBackups,
+		MaxAge:     out.MaxAge,
+		Compress:   out.CompressBackups,
+	}
+	return l, l, nil
 }
 
 func alternateFileName(filename string) string {
@@ -204,28 +567,27 @@ func ensureFileParentDir(path string) error {
 	return nil
 }
 
-func touchFile(path string) error {
-	var err error
-	if err = ensureFileParentDir(path); err != nil {
-		return err
-	}
-	fd, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0644))
-	if err != nil {
-		return err
-	}
-	if err = fd.Close(); err != nil {
-		return err
-	}
-	return nil
-}
-
 // Enums
 
 type (
 	commonTargetEnum struct {
-		TargetStdOut string
-		TargetStdErr string
-		TargetNull   string
+		TargetStdOut   string
+		TargetStdErr   string
+		TargetNull     string
+		TargetSyslog   string
+		TargetJournald string
+	}
+
+	formatEnum struct {
+		Text   string
+		JSON   string
+		Logfmt string
+	}
+
+	rotationScheduleEnum struct {
+		None   string
+		Hourly string
+		Daily  string
 	}
 
 	verbosityLevel struct {
@@ -239,9 +601,23 @@ type (
 
 var (
 	CommonTargets = commonTargetEnum{
-		TargetStdOut: ":stdout",
-		TargetStdErr: ":stderr",
-		TargetNull:   ":null",
+		TargetStdOut:   ":stdout",
+		TargetStdErr:   ":stderr",
+		TargetNull:     ":null",
+		TargetSyslog:   ":syslog",
+		TargetJournald: ":journald",
+	}
+
+	Formats = formatEnum{
+		Text:   "text",
+		JSON:   "json",
+		Logfmt: "logfmt",
+	}
+
+	RotationSchedules = rotationScheduleEnum{
+		None:   "",
+		Hourly: "hourly",
+		Daily:  "daily",
 	}
 
 	VerbosityLevel = verbosityLevel{
@@ -0,0 +1,414 @@
+package logconfig
+
+import (
+	"bufio"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/cert"
+	"github.com/prasannavl/go-gluons/log"
+)
+
+// initForTest runs InitE, and restores the global and std loggers it
+// replaces when the test ends.
+func initForTest(t *testing.T, opts *Options) LogInitResult {
+	prev := log.GetLogger()
+	prevStd := stdlog.Writer()
+	t.Cleanup(func() {
+		log.SetLogger(prev)
+		stdlog.SetOutput(prevStd)
+	})
+	opts.SlogDefault = false
+	res, err := InitE(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Close() })
+	return res
+}
+
+func TestInitMultipleOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jsonFile := filepath.Join(dir, "app.json")
+	textFile := filepath.Join(dir, "errors.log")
+	opts := DefaultOptions()
+	opts.Outputs = []OutputOptions{
+		{VerbosityLevel: VerbosityLevel.Info, LogFile: jsonFile, Format: Formats.JSON},
+		{VerbosityLevel: VerbosityLevel.Error, LogFile: textFile, Format: Formats.Text},
+		{VerbosityLevel: VerbosityLevel.Trace, LogFile: CommonTargets.TargetNull},
+	}
+	res := initForTest(t, &opts)
+
+	if len(res.Outputs) != 2 || res.Filename != jsonFile || res.Outputs[1].Filename != textFile {
+		t.Fatalf("unexpected outputs %+v", res.Outputs)
+	}
+	// The logger is filtered at the most verbose of the outputs, and
+	// each of them at its own level.
+	if res.Logger.IsEnabled(log.DebugLevel) || !res.Logger.IsEnabled(log.InfoLevel) {
+		t.Errorf("expected the logger to be filtered at info")
+	}
+	log.SetFlags(res.Logger, 0)
+	log.Info("started")
+	log.Errorw("failed", log.Int("code", 7))
+	log.Debug("hidden")
+	if err := res.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, jsonFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records in the json output, got %q", lines)
+	}
+	for i, msg := range []string{"started", "failed"} {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &m); err != nil {
+			t.Fatalf("invalid json %q: %v", lines[i], err)
+		}
+		if m["msg"] != msg {
+			t.Errorf("expected %q, got %q", msg, m["msg"])
+		}
+	}
+	expected := "error\tfailed\tcode=7\r\n"
+	if got := readFile(t, textFile); got != expected {
+		t.Errorf("expected %q in the text output, got %q", expected, got)
+	}
+}
+
+func TestInitSingleOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := DefaultOptions()
+	opts.LogFile = filepath.Join(dir, "app.log")
+	opts.Rolling = false
+	opts.Humanize = false
+	opts.VerbosityLevel = VerbosityLevel.Debug
+	res := initForTest(t, &opts)
+	if len(res.Outputs) != 1 || res.Filename != opts.LogFile {
+		t.Fatalf("unexpected outputs %+v", res.Outputs)
+	}
+	log.SetFlags(res.Logger, 0)
+	log.Debug("one")
+	log.Trace("two")
+	res.Close()
+	if got := readFile(t, opts.LogFile); got != "debug\tone\r\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestInitFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// A directory can't be opened for writing, so the PID is appended.
+	taken := filepath.Join(dir, "taken.log")
+	if err := os.Mkdir(taken, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// No directory can be created under a file, so both names fail.
+	blocker := filepath.Join(dir, "blocker")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	blocked := filepath.Join(blocker, "app.log")
+	opts := DefaultOptions()
+	opts.Outputs = []OutputOptions{
+		{VerbosityLevel: VerbosityLevel.Info, LogFile: taken},
+		{VerbosityLevel: VerbosityLevel.Info, LogFile: blocked},
+	}
+	res := initForTest(t, &opts)
+
+	first := res.Outputs[0]
+	if !first.IsFallback() || first.Filename != alternateFileName(taken) || len(first.Errors) != 1 {
+		t.Fatalf("unexpected fallback %+v", first)
+	}
+	var ierr *InitError
+	if !errors.As(first.Errors[0], &ierr) || ierr.Kind != ErrKindOpen || ierr.Path != taken {
+		t.Errorf("unexpected error %v", first.Errors[0])
+	}
+
+	second := res.Outputs[1]
+	if second.Filename != CommonTargets.TargetStdErr || second.Writer != os.Stderr || len(second.Errors) != 2 {
+		t.Fatalf("unexpected fallback %+v", second)
+	}
+	for i, name := range []string{blocked, alternateFileName(blocked)} {
+		if !errors.As(second.Errors[i], &ierr) || ierr.Kind != ErrKindCreateDir || ierr.Path != name {
+			t.Errorf("unexpected error %v", second.Errors[i])
+		}
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*Options)
+		kind   InitErrorKind
+	}{
+		{"redaction", func(o *Options) { o.RedactPatterns = []string{"("} }, ErrKindRedaction},
+		{"negative", func(o *Options) { o.MaxBackups = -1 }, ErrKindRotation},
+		{"schedule", func(o *Options) { o.RotationSchedule = "weekly" }, ErrKindRotation},
+	}
+	for _, c := range cases {
+		opts := DefaultOptions()
+		opts.LogFile = CommonTargets.TargetStdErr
+		c.modify(&opts)
+		res, err := InitE(&opts)
+		var ierr *InitError
+		if !errors.As(err, &ierr) || ierr.Kind != c.kind {
+			t.Errorf("%s: expected an error of kind %q, got %v", c.name, c.kind, err)
+		}
+		if res.Enabled || res.Logger != nil {
+			t.Errorf("%s: expected no result, got %+v", c.name, res)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setEnv(t, nil)
+	prev := log.GetLogger()
+	prevStd := stdlog.Writer()
+	defer func() {
+		log.SetLogger(prev)
+		stdlog.SetOutput(prevStd)
+	}()
+	override := func(o *Options) {
+		o.SlogDefault = false
+		o.Humanize = false
+	}
+
+	first := filepath.Join(dir, "first.log")
+	path := writeConfig(t, dir, "app.yaml", "level: info\nrolling: false\nfile: "+first+"\n")
+	var res LogInitResult
+	if err := Reload(path, override, &res); err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+	old := res.Logger
+	log.SetFlags(old, 0)
+	log.Info("one")
+
+	second := filepath.Join(dir, "second.log")
+	writeConfig(t, dir, "app.yaml", "level: info\nrolling: false\nfile: "+second+"\n")
+	if err := Reload(path, override, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Filename != second || log.GetLogger() != res.Logger {
+		t.Fatalf("expected the new logger in place, got %+v", res)
+	}
+	log.SetFlags(res.Logger, 0)
+	log.Info("two")
+	res.Logger.Flush()
+	if got := readFile(t, first); got != "info\tone\r\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+	if got := readFile(t, second); got != "info\ttwo\r\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	// A failure leaves the previous logger in place.
+	writeConfig(t, dir, "app.yaml", "level: loud\n")
+	if err := Reload(path, override, &res); err == nil {
+		t.Fatal("expected an error")
+	}
+	if res.Filename != second || log.GetLogger() != res.Logger {
+		t.Errorf("expected the previous logger in place, got %+v", res)
+	}
+
+	// Once every output is disabled, nothing is written to the old ones.
+	writeConfig(t, dir, "app.yaml", "level: off\n")
+	if err := Reload(path, override, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Enabled || log.GetLogger() != log.NopLogger {
+		t.Errorf("expected logging to be disabled, got %+v", res)
+	}
+	log.Info("three")
+	stdlog.Print("three")
+	if got := readFile(t, second); got != "info\ttwo\r\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestInitSrcPathMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := DefaultOptions()
+	opts.LogFile = filepath.Join(dir, "app.log")
+	opts.Rolling = false
+	opts.Humanize = false
+	opts.VerbosityLevel = VerbosityLevel.Info
+	opts.SrcPathMode = log.SrcPathPackage
+	res := initForTest(t, &opts)
+	log.SetFlags(res.Logger, log.FlagSrcHint)
+	log.Info("hint")
+	res.Close()
+	if got := readFile(t, opts.LogFile); !strings.HasPrefix(got, "info\thint\tlogconfig/config_test.go\t") {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestParseNetworkTarget(t *testing.T) {
+	cases := []struct {
+		target  string
+		network string
+		address string
+		ok      bool
+	}{
+		{"tcp://localhost:514", "tcp", "localhost:514", true},
+		{"tls://logs.example.com:6514", "tcp", "logs.example.com:6514", true},
+		{"udp://127.0.0.1:514", "udp", "127.0.0.1:514", true},
+		{"unix:///var/run/log.sock", "unix", "/var/run/log.sock", true},
+		{"unixgram:///dev/log", "unixgram", "/dev/log", true},
+		{"http://localhost:80", "", "", false},
+		{"tcp://", "", "", false},
+		{"tcp:localhost:514", "", "", false},
+		{"app.log", "", "", false},
+		{CommonTargets.TargetStdErr, "", "", false},
+	}
+	for _, c := range cases {
+		network, address, ok := parseNetworkTarget(c.target)
+		if network != c.network || address != c.address || ok != c.ok {
+			t.Errorf("%s: expected (%q, %q, %v), got (%q, %q, %v)",
+				c.target, c.network, c.address, c.ok, network, address, ok)
+		}
+	}
+}
+
+// writeCert writes the certificate and the key of c as PEM files in dir.
+func writeCert(t *testing.T, dir string, c tls.Certificate) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(c.PrivateKey.(*rsa.PrivateKey)),
+	})
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestInitTLSOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := cert.CreateSelfSignedRandomX509("logconfig-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeCert(t, dir, c)
+	// The server only accepts clients with the certificate.
+	clients := x509.NewCertPool()
+	clients.AppendCertsFromPEM([]byte(readFile(t, certFile)))
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{c},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	opts := DefaultOptions()
+	opts.LogFile = "tls://" + ln.Addr().String()
+	opts.Humanize = false
+	opts.VerbosityLevel = VerbosityLevel.Info
+	opts.TLSCAFile = certFile
+	opts.TLSCertFile = certFile
+	opts.TLSKeyFile = keyFile
+	res := initForTest(t, &opts)
+	if len(res.Outputs[0].Errors) != 0 {
+		t.Fatalf("unexpected errors %v", res.Outputs[0].Errors)
+	}
+	log.SetFlags(res.Logger, 0)
+	log.Info("secret")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "info\tsecret\n" {
+		t.Errorf("unexpected message %q", line)
+	}
+}
+
+func TestTLSConfigFromOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := cert.CreateSelfSignedRandomX509("logconfig-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeCert(t, dir, c)
+
+	config, err := tlsConfigFromOptions(&OutputOptions{TLSInsecure: true})
+	if err != nil || !config.InsecureSkipVerify || config.RootCAs != nil || len(config.Certificates) != 0 {
+		t.Errorf("unexpected config %+v, %v", config, err)
+	}
+	config, err = tlsConfigFromOptions(&OutputOptions{TLSCAFile: certFile, TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil || config.InsecureSkipVerify || config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Errorf("unexpected config %+v, %v", config, err)
+	}
+	for name, out := range map[string]OutputOptions{
+		"missing ca":   {TLSCAFile: filepath.Join(dir, "missing.pem")},
+		"invalid ca":   {TLSCAFile: keyFile},
+		"missing key":  {TLSCertFile: certFile},
+		"mismatch key": {TLSCertFile: keyFile, TLSKeyFile: certFile},
+	} {
+		if _, err := tlsConfigFromOptions(&out); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Outputs that can't be configured fall back to stderr.
+	opts := DefaultOptions()
+	opts.LogFile = "tls://127.0.0.1:6514"
+	opts.TLSCAFile = keyFile
+	res := initForTest(t, &opts)
+	out := res.Outputs[0]
+	var ierr *InitError
+	if out.Writer != os.Stderr || len(out.Errors) != 1 || !errors.As(out.Errors[0], &ierr) || ierr.Kind != ErrKindSink {
+		t.Errorf("unexpected fallback %+v", out)
+	}
+}
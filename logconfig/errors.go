@@ -0,0 +1,49 @@
+package logconfig
+
+type InitErrorKind int
+
+const (
+	ErrKindOpen InitErrorKind = iota
+	ErrKindPermission
+	ErrKindCreateDir
+	ErrKindRotation
+	ErrKindSink
+	ErrKindRedaction
+)
+
+func (k InitErrorKind) String() string {
+	switch k {
+	case ErrKindPermission:
+		return "permission denied"
+	case ErrKindCreateDir:
+		return "directory creation failed"
+	case ErrKindRotation:
+		return "rotation misconfigured"
+	case ErrKindSink:
+		return "sink creation failed"
+	case ErrKindRedaction:
+		return "redaction misconfigured"
+	}
+	return "open failed"
+}
+
+type InitError struct {
+	Kind InitErrorKind
+	Path string
+	Err  error
+}
+
+func (e *InitError) Error() string {
+	msg := "logconfig: " + e.Kind.String()
+	if e.Path != "" {
+		msg += ": " + e.Path
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *InitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,357 @@
+package logconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/prasannavl/go-gluons/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the declarative form of Options, as read from config files.
+// Fields that are not set leave the corresponding option untouched.
+type Config struct {
+	Level          string         `json:"level" yaml:"level" toml:"level"`
+	File           string         `json:"file" yaml:"file" toml:"file"`
+	Format         string         `json:"format" yaml:"format" toml:"format"`
+	Humanize       *bool          `json:"humanize" yaml:"humanize" toml:"humanize"`
+	Color          *bool          `json:"color" yaml:"color" toml:"color"`
+	Pretty         *bool          `json:"pretty" yaml:"pretty" toml:"pretty"`
+	Rolling        *bool          `json:"rolling" yaml:"rolling" toml:"rolling"`
+	MaxSize        *int           `json:"max_size" yaml:"max_size" toml:"max_size"`
+	MaxBackups     *int           `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	MaxAge         *int           `json:"max_age" yaml:"max_age" toml:"max_age"`
+	Compress       *bool          `json:"compress" yaml:"compress" toml:"compress"`
+	Schedule       string         `json:"schedule" yaml:"schedule" toml:"schedule"`
+	TLSCA          string         `json:"tls_ca" yaml:"tls_ca" toml:"tls_ca"`
+	TLSCert        string         `json:"tls_cert" yaml:"tls_cert" toml:"tls_cert"`
+	TLSKey         string         `json:"tls_key" yaml:"tls_key" toml:"tls_key"`
+	TLSInsecure    *bool          `json:"tls_insecure" yaml:"tls_insecure" toml:"tls_insecure"`
+	RotateOnSignal *bool          `json:"rotate_on_signal" yaml:"rotate_on_signal" toml:"rotate_on_signal"`
+	Mutex          *bool          `json:"mutex" yaml:"mutex" toml:"mutex"`
+	StdLevel       string         `json:"std_level" yaml:"std_level" toml:"std_level"`
+	StackLevel     string         `json:"stack_level" yaml:"stack_level" toml:"stack_level"`
+	RedactFields   []string       `json:"redact_fields" yaml:"redact_fields" toml:"redact_fields"`
+	RedactPatterns []string       `json:"redact_patterns" yaml:"redact_patterns" toml:"redact_patterns"`
+	Outputs        []OutputConfig `json:"outputs" yaml:"outputs" toml:"outputs"`
+}
+
+type OutputConfig struct {
+	Level       string `json:"level" yaml:"level" toml:"level"`
+	File        string `json:"file" yaml:"file" toml:"file"`
+	Format      string `json:"format" yaml:"format" toml:"format"`
+	Humanize    *bool  `json:"humanize" yaml:"humanize" toml:"humanize"`
+	Color       *bool  `json:"color" yaml:"color" toml:"color"`
+	Pretty      *bool  `json:"pretty" yaml:"pretty" toml:"pretty"`
+	Rolling     *bool  `json:"rolling" yaml:"rolling" toml:"rolling"`
+	MaxSize     *int   `json:"max_size" yaml:"max_size" toml:"max_size"`
+	MaxBackups  *int   `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	MaxAge      *int   `json:"max_age" yaml:"max_age" toml:"max_age"`
+	Compress    *bool  `json:"compress" yaml:"compress" toml:"compress"`
+	Schedule    string `json:"schedule" yaml:"schedule" toml:"schedule"`
+	TLSCA       string `json:"tls_ca" yaml:"tls_ca" toml:"tls_ca"`
+	TLSCert     string `json:"tls_cert" yaml:"tls_cert" toml:"tls_cert"`
+	TLSKey      string `json:"tls_key" yaml:"tls_key" toml:"tls_key"`
+	TLSInsecure *bool  `json:"tls_insecure" yaml:"tls_insecure" toml:"tls_insecure"`
+}
+
+type ConfigError struct {
+	Source string
+	Key    string
+	Value  string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	msg := "logconfig: " + e.Source
+	if e.Key != "" {
+		msg += ": " + e.Key
+	}
+	if e.Value != "" {
+		msg += " (" + strconv.Quote(e.Value) + ")"
+	}
+	return msg + ": " + e.Reason
+}
+
+const (
+	EnvLogLevel    = "GLUONS_LOG_LEVEL"
+	EnvLogFile     = "GLUONS_LOG_FILE"
+	EnvLogFormat   = "GLUONS_LOG_FORMAT"
+	EnvLogColor    = "GLUONS_LOG_COLOR"
+	EnvLogHumanize = "GLUONS_LOG_HUMANIZE"
+)
+
+// Load returns the default options, overridden by the config file at
+// path (if path isn't empty), which in turn are overridden by the
+// environment. Command line flags are expected to be applied by the
+// caller on top of these.
+func Load(path string) (Options, error) {
+	opts := DefaultOptions()
+	if path != "" {
+		if err := LoadFile(path, &opts); err != nil {
+			return opts, err
+		}
+	}
+	if err := LoadEnv(&opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// LoadFile applies the config file at path onto opts. The format is
+// picked by the extension: .json, .yaml, .yml or .toml
+func LoadFile(path string, opts *Options) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var c Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&c)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &c)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(data), &c); err == nil {
+			if keys := md.Undecoded(); len(keys) > 0 {
+				err = errors.New("unknown field " + strconv.Quote(keys[0].String()))
+			}
+		}
+	default:
+		return &ConfigError{Source: path, Reason: "unknown config file format"}
+	}
+	if err != nil {
+		return &ConfigError{Source: path, Reason: err.Error()}
+	}
+	return c.apply(path, opts)
+}
+
+// LoadEnv applies the environment variables onto opts. When opts has
+// multiple outputs, the level applies to each of them.
+func LoadEnv(opts *Options) error {
+	const source = "env"
+	var c Config
+	c.Level = os.Getenv(EnvLogLevel)
+	c.File = os.Getenv(EnvLogFile)
+	c.Format = os.Getenv(EnvLogFormat)
+	for _, x := range []struct {
+		key    string
+		target **bool
+	}{{EnvLogColor, &c.Color}, {EnvLogHumanize, &c.Humanize}} {
+		if v := os.Getenv(x.key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return &ConfigError{Source: source, Key: x.key, Value: v, Reason: "not a boolean"}
+			}
+			*x.target = &b
+		}
+	}
+	if err := c.apply(source, opts); err != nil {
+		return err
+	}
+	if c.Level != "" {
+		for i := range opts.Outputs {
+			if err := applyLevel(source, c.Level, &opts.Outputs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) apply(source string, opts *Options) error {
+	out := outputFromOptions(opts)
+	oc := OutputConfig{
+		Level:       c.Level,
+		File:        c.File,
+		Format:      c.Format,
+		Humanize:    c.Humanize,
+		Color:       c.Color,
+		Pretty:      c.Pretty,
+		Rolling:     c.Rolling,
+		MaxSize:     c.MaxSize,
+		MaxBackups:  c.MaxBackups,
+		MaxAge:      c.MaxAge,
+		Compress:    c.Compress,
+		Schedule:    c.Schedule,
+		TLSCA:       c.TLSCA,
+		TLSCert:     c.TLSCert,
+		TLSKey:      c.TLSKey,
+		TLSInsecure: c.TLSInsecure,
+	}
+	if err := oc.apply(source, &out); err != nil {
+		return err
+	}
+	opts.VerbosityLevel = out.VerbosityLevel
+	opts.LogFile = out.LogFile
+	opts.Rolling = out.Rolling
+	opts.MaxSize = out.MaxSize
+	opts.MaxBackups = out.MaxBackups
+	opts.MaxAge = out.MaxAge
+	opts.CompressBackups = out.CompressBackups
+	opts.RotationSchedule = out.RotationSchedule
+	opts.Format = out.Format
+	opts.Humanize = out.Humanize
+	opts.EnableColor = out.EnableColor
+	opts.PrettyStructs = out.PrettyStructs
+	opts.TLSCAFile = out.TLSCAFile
+	opts.TLSCertFile = out.TLSCertFile
+	opts.TLSKeyFile = out.TLSKeyFile
+	opts.TLSInsecure = out.TLSInsecure
+
+	if c.RotateOnSignal != nil {
+		opts.RotateOnSignal = *c.RotateOnSignal
+	}
+	if c.Mutex != nil {
+		opts.LoggerMutex = *c.Mutex
+	}
+	if c.StdLevel != "" {
+		lvl, err := log.ParseLevel(c.StdLevel)
+		if err != nil || lvl == log.DisabledLevel {
+			return &ConfigError{Source: source, Key: "std_level", Value: c.StdLevel, Reason: "unknown level"}
+		}
+		opts.StdLogLevel = lvl
+	}
+	if c.StackLevel != "" {
+		lvl, err := log.ParseLevel(c.StackLevel)
+		if err != nil {
+			return &ConfigError{Source: source, Key: "stack_level", Value: c.StackLevel, Reason: "unknown level"}
+		}
+		opts.StackLevel = lvl
+	}
+	if c.RedactFields != nil {
+		opts.RedactFields = c.RedactFields
+	}
+	for _, p := range c.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return &ConfigError{Source: source, Key: "redact_patterns", Value: p, Reason: err.Error()}
+		}
+	}
+	if c.RedactPatterns != nil {
+		opts.RedactPatterns = c.RedactPatterns
+	}
+	if len(c.Outputs) > 0 {
+		outputs := make([]OutputOptions, len(c.Outputs))
+		for i := range c.Outputs {
+			outputs[i] = DefaultOutputOptions()
+			src := source + ": outputs[" + strconv.Itoa(i) + "]"
+			if err := c.Outputs[i].apply(src, &outputs[i]); err != nil {
+				return err
+			}
+		}
+		opts.Outputs = outputs
+	}
+	return nil
+}
+
+func (c *OutputConfig) apply(source string, out *OutputOptions) error {
+	if c.File != "" {
+		out.LogFile = c.File
+	}
+	if c.Level != "" {
+		if err := applyLevel(source, c.Level, out); err != nil {
+			return err
+		}
+	}
+	if c.Format != "" {
+		switch f := strings.ToLower(c.Format); f {
+		case Formats.Text, Formats.JSON, Formats.Logfmt:
+			out.Format = f
+		default:
+			return &ConfigError{Source: source, Key: "format", Value: c.Format, Reason: "unknown format"}
+		}
+	}
+	if c.Humanize != nil {
+		out.Humanize = *c.Humanize
+	}
+	if c.Color != nil {
+		out.EnableColor = *c.Color
+	}
+	if c.Pretty != nil {
+		out.PrettyStructs = *c.Pretty
+	}
+	if c.Rolling != nil {
+		out.Rolling = *c.Rolling
+	}
+	for _, x := range []struct {
+		key    string
+		value  *int
+		target *int
+	}{
+		{"max_size", c.MaxSize, &out.MaxSize},
+		{"max_backups", c.MaxBackups, &out.MaxBackups},
+		{"max_age", c.MaxAge, &out.MaxAge},
+	} {
+		if x.value == nil {
+			continue
+		}
+		if *x.value < 0 {
+			return &ConfigError{Source: source, Key: x.key, Value: strconv.Itoa(*x.value), Reason: "cannot be negative"}
+		}
+		*x.target = *x.value
+	}
+	if c.Compress != nil {
+		out.CompressBackups = *c.Compress
+	}
+	if c.TLSCA != "" {
+		out.TLSCAFile = c.TLSCA
+	}
+	if c.TLSCert != "" {
+		out.TLSCertFile = c.TLSCert
+	}
+	if c.TLSKey != "" {
+		out.TLSKeyFile = c.TLSKey
+	}
+	if c.TLSInsecure != nil {
+		out.TLSInsecure = *c.TLSInsecure
+	}
+	if c.Schedule != "" {
+		switch sch := strings.ToLower(c.Schedule); sch {
+		case RotationSchedules.Hourly, RotationSchedules.Daily:
+			out.RotationSchedule = sch
+		default:
+			return &ConfigError{Source: source, Key: "schedule", Value: c.Schedule, Reason: "unknown rotation schedule"}
+		}
+	}
+	return nil
+}
+
+func applyLevel(source string, level string, out *OutputOptions) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return &ConfigError{Source: source, Key: "level", Value: level, Reason: "unknown level"}
+	}
+	if lvl == log.DisabledLevel {
+		out.LogFile = CommonTargets.TargetNull
+		return nil
+	}
+	out.VerbosityLevel = VerbosityLevelFromLogLevel(lvl)
+	return nil
+}
+
+// VerbosityLevelFromLogLevel returns the least verbosity level that
+// includes lvl, so that custom levels are rounded up.
+func VerbosityLevelFromLogLevel(lvl log.Level) int {
+	switch {
+	case lvl <= log.ErrorLevel:
+		return VerbosityLevel.Error
+	case lvl <= log.WarnLevel:
+		return VerbosityLevel.Warn
+	case lvl <= log.InfoLevel:
+		return VerbosityLevel.Info
+	case lvl <= log.DebugLevel:
+		return VerbosityLevel.Debug
+	}
+	return VerbosityLevel.Trace
+}
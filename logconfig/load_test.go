@@ -0,0 +1,162 @@
+package logconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+func writeConfig(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setEnv sets the environment variables for the test, and unsets all
+// the others that Load reads.
+func setEnv(t *testing.T, vars map[string]string) {
+	for _, key := range []string{EnvLogLevel, EnvLogFile, EnvLogFormat, EnvLogColor, EnvLogHumanize} {
+		t.Setenv(key, vars[key])
+		if _, ok := vars[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setEnv(t, nil)
+	files := map[string]string{
+		"app.json": `{"level": "debug", "file": "app.log", "format": "json", "rolling": false,
+			"max_backups": 5, "stack_level": "error", "redact_fields": ["password"],
+			"outputs": [{"file": ":stderr", "level": "warn", "format": "logfmt"}]}`,
+		"app.yaml": "level: debug\nfile: app.log\nformat: json\nrolling: false\n" +
+			"max_backups: 5\nstack_level: error\nredact_fields: [password]\n" +
+			"outputs:\n  - file: \":stderr\"\n    level: warn\n    format: logfmt\n",
+		"app.toml": "level = \"debug\"\nfile = \"app.log\"\nformat = \"json\"\nrolling = false\n" +
+			"max_backups = 5\nstack_level = \"error\"\nredact_fields = [\"password\"]\n" +
+			"[[outputs]]\nfile = \":stderr\"\nlevel = \"warn\"\nformat = \"logfmt\"\n",
+	}
+	for name, content := range files {
+		opts, err := Load(writeConfig(t, dir, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if opts.VerbosityLevel != VerbosityLevel.Debug || opts.LogFile != "app.log" ||
+			opts.Format != Formats.JSON || opts.Rolling || opts.MaxBackups != 5 {
+			t.Errorf("%s: unexpected options %+v", name, opts)
+		}
+		// Keys that aren't set keep their defaults.
+		if opts.MaxSize != DefaultOptions().MaxSize || !opts.Humanize {
+			t.Errorf("%s: expected the defaults to be kept, got %+v", name, opts)
+		}
+		if opts.StackLevel != log.ErrorLevel || len(opts.RedactFields) != 1 || opts.RedactFields[0] != "password" {
+			t.Errorf("%s: unexpected options %+v", name, opts)
+		}
+		if len(opts.Outputs) != 1 {
+			t.Fatalf("%s: unexpected outputs %+v", name, opts.Outputs)
+		}
+		out := opts.Outputs[0]
+		if out.LogFile != CommonTargets.TargetStdErr || out.VerbosityLevel != VerbosityLevel.Warn ||
+			out.Format != Formats.Logfmt || out.MaxSize != DefaultOptions().MaxSize {
+			t.Errorf("%s: unexpected output %+v", name, out)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, "app.yaml",
+		"level: debug\nformat: json\ncolor: true\noutputs:\n  - file: a.log\n  - file: b.log\n    level: error\n")
+	setEnv(t, map[string]string{
+		EnvLogLevel:    "trace",
+		EnvLogFile:     "env.log",
+		EnvLogColor:    "false",
+		EnvLogHumanize: "0",
+	})
+
+	// The environment takes precedence over the file, that takes
+	// precedence over the defaults.
+	opts, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.VerbosityLevel != VerbosityLevel.Trace || opts.LogFile != "env.log" ||
+		opts.Format != Formats.JSON || opts.EnableColor || opts.Humanize {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	// The level applies to each of the outputs.
+	for i, out := range opts.Outputs {
+		if out.VerbosityLevel != VerbosityLevel.Trace {
+			t.Errorf("outputs[%d]: expected the level of the env, got %d", i, out.VerbosityLevel)
+		}
+	}
+
+	t.Setenv(EnvLogLevel, "off")
+	opts, err = Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.LogFile != CommonTargets.TargetNull {
+		t.Errorf("expected the output to be disabled, got %q", opts.LogFile)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setEnv(t, nil)
+	cases := []struct {
+		name    string
+		content string
+		key     string
+	}{
+		{"level.yaml", "level: loud\n", "level"},
+		{"format.json", `{"format": "xml"}`, "format"},
+		{"schedule.toml", "schedule = \"weekly\"\n", "schedule"},
+		{"negative.yaml", "max_size: -1\n", "max_size"},
+		{"pattern.json", `{"redact_patterns": ["("]}`, "redact_patterns"},
+		{"std.yaml", "std_level: off\n", "std_level"},
+		{"output.yaml", "outputs:\n  - format: xml\n", "format"},
+		{"unknown.json", `{"levle": "info"}`, ""},
+		{"unknown.yaml", "levle: info\n", ""},
+		{"unknown.toml", "levle = \"info\"\n", ""},
+		{"app.ini", "level=info\n", ""},
+	}
+	for _, c := range cases {
+		path := writeConfig(t, dir, c.name, c.content)
+		_, err := Load(path)
+		cerr, ok := err.(*ConfigError)
+		if !ok {
+			t.Errorf("%s: expected a config error, got %v", c.name, err)
+			continue
+		}
+		if cerr.Source != path && !strings.HasPrefix(cerr.Source, path+": outputs[") || cerr.Key != c.key {
+			t.Errorf("%s: unexpected error %+v", c.name, cerr)
+		}
+	}
+
+	t.Setenv(EnvLogColor, "maybe")
+	_, err = Load("")
+	if cerr, ok := err.(*ConfigError); !ok || cerr.Source != "env" || cerr.Key != EnvLogColor {
+		t.Errorf("expected an error of the env, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package logconfig
+
+import "github.com/prasannavl/go-gluons/log"
+
+// OutputOptions describes one of the destinations of the logger, each
+// with its own level and format.
+type OutputOptions struct {
+	VerbosityLevel int
+	LogFile        string
+
+	Rolling         bool
+	MaxSize         int // megabytes
+	MaxBackups      int
+	MaxAge          int // days
+	CompressBackups bool
+	// RotationSchedule rotates rolling files hourly or daily, in
+	// addition to rotating them by size.
+	RotationSchedule string
+	Format           string
+	Humanize         bool
+	EnableColor      bool
+	Theme            *log.Theme
+	PrettyStructs    bool
+	SrcPathMode      log.SrcPathMode
+	// TLSCAFile, TLSCertFile and TLSKeyFile are the PEM files of the CAs
+	// that the server of tls:// targets is verified with, in place of
+	// the ones of the system, and of the client certificate.
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSInsecure skips the verification of the server.
+	TLSInsecure bool
+}
+
+func DefaultOutputOptions() OutputOptions {
+	opts := DefaultOptions()
+	return outputFromOptions(&opts)
+}
+
+func outputFromOptions(opts *Options) OutputOptions {
+	return OutputOptions{
+		VerbosityLevel:   opts.VerbosityLevel,
+		LogFile:          opts.LogFile,
+		Rolling:          opts.Rolling,
+		MaxSize:          opts.MaxSize,
+		MaxBackups:       opts.MaxBackups,
+		MaxAge:           opts.MaxAge,
+		CompressBackups:  opts.CompressBackups,
+		RotationSchedule: opts.RotationSchedule,
+		Format:           opts.Format,
+		Humanize:         opts.Humanize,
+		EnableColor:      opts.EnableColor,
+		Theme:            opts.Theme,
+		PrettyStructs:    opts.PrettyStructs,
+		SrcPathMode:      opts.SrcPathMode,
+		TLSCAFile:        opts.TLSCAFile,
+		TLSCertFile:      opts.TLSCertFile,
+		TLSKeyFile:       opts.TLSKeyFile,
+		TLSInsecure:      opts.TLSInsecure,
+	}
+}
+
+func outputsFromOptions(opts *Options) []OutputOptions {
+	if len(opts.Outputs) > 0 {
+		return opts.Outputs
+	}
+	return []OutputOptions{outputFromOptions(opts)}
+}
@@ -0,0 +1,322 @@
+package logconfig
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.WriteCloser that rotates the file on a schedule,
+// on reaching a size, or when Rotate is called.
+//
+// The filename may contain Go time layouts in braces, like
+// "logs/app-{2006-01-02}.log", in which case a new file is started
+// whenever the expanded name changes. Otherwise, or when a file has to
+// be rotated within the same period, it's renamed to a backup with the
+// time of the rotation appended to its name.
+type RotatingFile struct {
+	Filename   string
+	Schedule   string
+	MaxSize    int // megabytes
+	MaxBackups int
+	MaxAge     int // days
+	Compress   bool
+	// PostRotate is called with the name of every file that's been
+	// rotated out, after it's been compressed.
+	PostRotate func(filename string)
+	// Now is the clock used for the schedule and names. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	m            sync.Mutex
+	file         *os.File
+	name         string
+	size         int64
+	nextRotation time.Time
+	wg           sync.WaitGroup
+}
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+func (f *RotatingFile) Write(p []byte) (n int, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	now := f.now()
+	if f.file == nil {
+		if err = f.open(now); err != nil {
+			return 0, err
+		}
+	} else if f.isDue(now, len(p)) {
+		if err = f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	now := f.now()
+	if f.file == nil {
+		return f.open(now)
+	}
+	return f.rotate(now)
+}
+
+// Close closes the current file, and waits for the post-rotation
+// work of the previous ones to finish.
+func (f *RotatingFile) Close() error {
+	f.m.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.m.Unlock()
+	f.wg.Wait()
+	return err
+}
+
+func (f *RotatingFile) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+	return time.Now()
+}
+
+func (f *RotatingFile) isDue(now time.Time, writeLen int) bool {
+	if !f.nextRotation.IsZero() && !now.Before(f.nextRotation) {
+		return true
+	}
+	if expandFilePattern(f.Filename, now) != f.name {
+		return true
+	}
+	return f.MaxSize > 0 && f.size+int64(writeLen) > int64(f.MaxSize)*1024*1024
+}
+
+func (f *RotatingFile) open(now time.Time) error {
+	name := expandFilePattern(f.Filename, now)
+	if err := ensureFileParentDir(name); err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	f.file = fd
+	f.name = name
+	f.size = info.Size()
+	f.nextRotation = nextRotationTime(f.Schedule, now)
+	return nil
+}
+
+func (f *RotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	closed := f.name
+	if expandFilePattern(f.Filename, now) == closed {
+		ext := filepath.Ext(closed)
+		backup := strings.TrimSuffix(closed, ext) + "-" + now.Format(backupTimeFormat) + ext
+		if err := os.Rename(closed, backup); err != nil {
+			return err
+		}
+		closed = backup
+	}
+	if err := f.open(now); err != nil {
+		return err
+	}
+	f.wg.Add(1)
+	go f.afterRotate(closed, now)
+	return nil
+}
+
+func (f *RotatingFile) afterRotate(closed string, now time.Time) {
+	defer f.wg.Done()
+	if f.Compress {
+		if err := gzipFile(closed); err == nil {
+			closed += ".gz"
+		}
+	}
+	f.prune(now)
+	if f.PostRotate != nil {
+		f.PostRotate(closed)
+	}
+}
+
+// prune removes the rotated files beyond MaxBackups, or older than MaxAge.
+func (f *RotatingFile) prune(now time.Time) {
+	if f.MaxBackups <= 0 && f.MaxAge <= 0 {
+		return
+	}
+	f.m.Lock()
+	current := f.name
+	f.m.Unlock()
+
+	var globs []string
+	if hasFilePattern(f.Filename) {
+		globs = append(globs, filePatternGlob(f.Filename))
+	} else {
+		ext := filepath.Ext(f.Filename)
+		prefix := strings.TrimSuffix(f.Filename, ext)
+		globs = append(globs, prefix+"-*"+ext, prefix+"-*"+ext+".gz")
+	}
+	type backup struct {
+		name    string
+		modTime time.Time
+	}
+	var backups []backup
+	seen := make(map[string]bool)
+	for _, g := range globs {
+		matches, _ := filepath.Glob(g)
+		for _, name := range matches {
+			if name == current || seen[name] || !f.isBackup(name) {
+				continue
+			}
+			seen[name] = true
+			if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+				backups = append(backups, backup{name, info.ModTime()})
+			}
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].modTime.Equal(backups[j].modTime) {
+			return backups[i].modTime.After(backups[j].modTime)
+		}
+		return backups[i].name > backups[j].name
+	})
+	cutoff := now.Add(-time.Duration(f.MaxAge) * 24 * time.Hour)
+	for i, b := range backups {
+		if (f.MaxBackups > 0 && i >= f.MaxBackups) || (f.MaxAge > 0 && b.modTime.Before(cutoff)) {
+			os.Remove(b.name)
+		}
+	}
+}
+
+// isBackup reports whether the file is one that was rotated out, rather
+// than one that only has a name like it, such as app-debug.log next to
+// app.log. Backups are named by the file pattern, if any, with the time
+// of rotation added when it was rotated for its size, and with ".gz"
+// when they're compressed.
+func (f *RotatingFile) isBackup(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if n := len(stem) - len(backupTimeFormat); n > 0 && stem[n-1] == '-' {
+		if _, err := time.Parse(backupTimeFormat, stem[n:]); err == nil {
+			return matchFilePattern(f.Filename, stem[:n-1]+ext)
+		}
+	}
+	return hasFilePattern(f.Filename) && matchFilePattern(f.Filename, name)
+}
+
+// matchFilePattern reports whether the name is the expansion of the file
+// pattern for some time.
+func matchFilePattern(pattern string, name string) bool {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return pattern == name
+	}
+	end := strings.IndexByte(pattern[start:], '}')
+	if end < 0 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, pattern[:start]) {
+		return false
+	}
+	layout := pattern[start+1 : start+end]
+	rest := pattern[start+end+1:]
+	name = name[start:]
+	for i := 1; i <= len(name); i++ {
+		if _, err := time.Parse(layout, name[:i]); err == nil && matchFilePattern(rest, name[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func nextRotationTime(schedule string, now time.Time) time.Time {
+	switch schedule {
+	case RotationSchedules.Hourly:
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
+	case RotationSchedules.Daily:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+func hasFilePattern(filename string) bool {
+	i := strings.IndexByte(filename, '{')
+	return i >= 0 && strings.IndexByte(filename[i:], '}') > 0
+}
+
+func expandFilePattern(filename string, t time.Time) string {
+	return replaceFilePattern(filename, func(layout string) string {
+		return t.Format(layout)
+	})
+}
+
+func filePatternGlob(filename string) string {
+	return replaceFilePattern(filename, func(string) string {
+		return "*"
+	}) + "*"
+}
+
+func replaceFilePattern(filename string, fn func(layout string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(filename, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(filename[start:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(filename[:start])
+		b.WriteString(fn(filename[start+1 : start+end]))
+		filename = filename[start+end+1:]
+	}
+	b.WriteString(filename)
+	return b.String()
+}
+
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err == nil {
+		err = w.Close()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,205 @@
+package logconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) Add(d time.Duration) { c.t = c.t.Add(d) }
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, e := range entries {
+		res = append(res, e.Name())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileSchedule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := &fakeClock{time.Date(2020, 1, 1, 23, 30, 0, 0, time.UTC)}
+	var m sync.Mutex
+	var rotated []string
+	f := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		Schedule: RotationSchedules.Daily,
+		Now:      clock.Now,
+		PostRotate: func(name string) {
+			m.Lock()
+			rotated = append(rotated, filepath.Base(name))
+			m.Unlock()
+		},
+	}
+	f.Write([]byte("one\n"))
+	clock.Add(20 * time.Minute)
+	f.Write([]byte("two\n"))
+	clock.Add(20 * time.Minute)
+	f.Write([]byte("three\n"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := "app-2020-01-02T00-10-00.000.log"
+	if got := listDir(t, dir); strings.Join(got, ",") != backup+",app.log" {
+		t.Fatalf("unexpected files: %v", got)
+	}
+	if got := readFile(t, filepath.Join(dir, backup)); got != "one\ntwo\n" {
+		t.Errorf("unexpected backup content: %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "three\n" {
+		t.Errorf("unexpected current content: %q", got)
+	}
+	if len(rotated) != 1 || rotated[0] != backup {
+		t.Errorf("unexpected post rotate calls: %v", rotated)
+	}
+}
+
+func TestRotatingFilePattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := &fakeClock{time.Date(2020, 1, 1, 10, 59, 0, 0, time.UTC)}
+	f := &RotatingFile{
+		Filename:   filepath.Join(dir, "app-{2006010215}.log"),
+		MaxBackups: 2,
+		Now:        clock.Now,
+	}
+	for i := 0; i < 4; i++ {
+		f.Write([]byte("x\n"))
+		clock.Add(time.Hour)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "app-2020010111.log,app-2020010112.log,app-2020010113.log"
+	if got := listDir(t, dir); strings.Join(got, ",") != want {
+		t.Fatalf("unexpected files: %v", got)
+	}
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxSize:  1,
+		Compress: true,
+		Now:      clock.Now,
+	}
+	half := make([]byte, 512*1024)
+	for i := 0; i < 3; i++ {
+		f.Write(half)
+		clock.Add(time.Second)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "app-2020-01-01T00-00-02.000.log.gz,app.log"
+	if got := listDir(t, dir); strings.Join(got, ",") != want {
+		t.Fatalf("unexpected files: %v", got)
+	}
+}
+
+func TestRotatingFilePruneNeighbours(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	neighbours := []string{"app-debug.log", "app-debug.log.gz", "app-2020.log", "app-x-2020-01-01T00-00-00.000.log"}
+	for _, name := range neighbours {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := &RotatingFile{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    1,
+		MaxBackups: 1,
+		Now:        clock.Now,
+	}
+	half := make([]byte, 512*1024)
+	for i := 0; i < 6; i++ {
+		f.Write(half)
+		clock.Add(time.Second)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "app-2020-01-01T00-00-04.000.log,app-2020.log,app-debug.log,app-debug.log.gz," +
+		"app-x-2020-01-01T00-00-00.000.log,app.log"
+	if got := listDir(t, dir); strings.Join(got, ",") != want {
+		t.Fatalf("unexpected files: %v", got)
+	}
+}
+
+func TestRotatingFilePatternPruneNeighbours(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "app-debug.log"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fakeClock{time.Date(2020, 1, 1, 10, 59, 0, 0, time.UTC)}
+	f := &RotatingFile{
+		Filename:   filepath.Join(dir, "app-{2006010215}.log"),
+		MaxBackups: 1,
+		Now:        clock.Now,
+	}
+	for i := 0; i < 3; i++ {
+		f.Write([]byte("x\n"))
+		clock.Add(time.Hour)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "app-2020010111.log,app-2020010112.log,app-debug.log"
+	if got := listDir(t, dir); strings.Join(got, ",") != want {
+		t.Fatalf("unexpected files: %v", got)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package logconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+var rotateSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,6 @@
+package logconfig
+
+import "os"
+
+// There's no equivalent of SIGUSR1 to rotate on.
+var rotateSignals []os.Signal
@@ -0,0 +1,19 @@
+//go:build go1.21
+// +build go1.21
+
+package logconfig
+
+import (
+	stdlog "log"
+	"log/slog"
+
+	"github.com/prasannavl/go-gluons/log"
+	"github.com/prasannavl/go-gluons/log/slogbridge"
+)
+
+func setSlogDefault(l *log.Logger) {
+	// slog.SetDefault also clears the flags of the std logger.
+	flags := stdlog.Flags()
+	slog.SetDefault(slog.New(slogbridge.NewHandler(l)))
+	stdlog.SetFlags(flags)
+}
@@ -0,0 +1,8 @@
+//go:build !go1.21
+// +build !go1.21
+
+package logconfig
+
+import "github.com/prasannavl/go-gluons/log"
+
+func setSlogDefault(l *log.Logger) {}
@@ -0,0 +1,153 @@
+package logconfig
+
+import (
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+type WatchOpts struct {
+	// Path of the config file. The configuration is still re-applied
+	// on signals when it's empty, picking up changes to the environment.
+	Path string
+	// PollInterval is how often the file is checked for changes.
+	// Zero disables it.
+	PollInterval time.Duration
+	Signals      []os.Signal
+	// Override is called with the loaded options before they're
+	// applied, so that command line flags can keep precedence.
+	Override func(*Options)
+	// Result is the result of the initial configuration. It's updated
+	// with every successful reload, after closing the previous outputs.
+	// It's only safe to use from OnReload, or after stop has returned.
+	Result *LogInitResult
+	// OnReload is called after every attempt to re-apply the config.
+	OnReload func(result *LogInitResult, err error)
+}
+
+func DefaultWatchOpts(path string) WatchOpts {
+	return WatchOpts{
+		Path:         path,
+		PollInterval: 5 * time.Second,
+		Signals:      []os.Signal{syscall.SIGHUP},
+	}
+}
+
+// reloadMu serializes the reloads, and with them the updates of their
+// results.
+var reloadMu sync.Mutex
+
+// Reload loads the config file at path and the environment, and
+// re-initializes the global logger with it. On success, the outputs of
+// the previous result are closed once the new logger is in place, and
+// it's replaced with the new one. On failure, the previous logger is
+// left in place.
+func Reload(path string, override func(*Options), result *LogInitResult) error {
+	opts, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if override != nil {
+		override(&opts)
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res, err := InitE(&opts)
+	if err != nil {
+		return err
+	}
+	if !res.Enabled {
+		// InitE leaves the loggers alone when every output is
+		// disabled, but they mustn't outlive the old outputs.
+		log.SetLogger(nil)
+		if opts.SlogDefault {
+			setSlogDefault(log.NopLogger)
+		}
+		stdlog.SetOutput(ioutil.Discard)
+	}
+	result.Close()
+	*result = res
+	return nil
+}
+
+// Watch reloads the configuration whenever the file changes or one of
+// the signals is received, until stop is called. Failures are logged,
+// unless OnReload is set. Stop waits for a reload in progress, so that
+// the result can be closed once it returns.
+func Watch(opts *WatchOpts) (stop func()) {
+	if opts == nil {
+		o := DefaultWatchOpts("")
+		opts = &o
+	}
+	sigs := make(chan os.Signal, 1)
+	if len(opts.Signals) > 0 {
+		signal.Notify(sigs, opts.Signals...)
+	}
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if opts.PollInterval > 0 && opts.Path != "" {
+		ticker = time.NewTicker(opts.PollInterval)
+		tick = ticker.C
+	}
+	result := opts.Result
+	if result == nil {
+		result = &LogInitResult{}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		last := statFile(opts.Path)
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+			case <-tick:
+				if current := statFile(opts.Path); current == last {
+					continue
+				}
+			}
+			last = statFile(opts.Path)
+			err := Reload(opts.Path, opts.Override, result)
+			if opts.OnReload != nil {
+				opts.OnReload(result, err)
+			} else if err != nil {
+				log.Errorf("logconfig: reload: %v", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			if ticker != nil {
+				ticker.Stop()
+			}
+			close(done)
+		})
+		<-exited
+	}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileStamp {
+	if path == "" {
+		return fileStamp{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+package logconfig
+
+import (
+	stdlog "log"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prasannavl/go-gluons/log"
+)
+
+func TestWatchStop(t *testing.T) {
+	setEnv(t, nil)
+	prev := log.GetLogger()
+	prevStd := stdlog.Writer()
+	defer func() {
+		log.SetLogger(prev)
+		stdlog.SetOutput(prevStd)
+	}()
+	var res LogInitResult
+	reloaded := make(chan struct{}, 1)
+	stop := Watch(&WatchOpts{
+		Signals: []os.Signal{syscall.SIGHUP},
+		Override: func(o *Options) {
+			o.SlogDefault = false
+			o.LogFile = CommonTargets.TargetStdErr
+		},
+		Result: &res,
+		OnReload: func(result *LogInitResult, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			reloaded <- struct{}{}
+		},
+	})
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a reload on the signal")
+	}
+	stop()
+	stop()
+	// Once stopped, the result is no longer written to.
+	if !res.Enabled || res.Filename != CommonTargets.TargetStdErr {
+		t.Errorf("unexpected result %+v", res)
+	}
+	res.Close()
+}
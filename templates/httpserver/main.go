@@ -2,8 +2,10 @@ package main
 
 import (
 	"net/http"
+	"os"
 	"path/filepath"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/prasannavl/go-gluons/templates/httpserver/app"
 	flag "github.com/spf13/pflag"
@@ -22,6 +24,7 @@ import (
 type EnvFlags struct {
 	Addr           string
 	LogFile        string
+	LogConfig      string
 	LogDisabled    bool
 	Verbosity      int
 	DisplayVersion bool
@@ -43,6 +46,7 @@ func initFlags(env *EnvFlags) {
 	flag.StringVarP(&env.Addr, "address", "a", "localhost:8000", "the 'host:port' for the service to listen on")
 	flag.StringVar(&env.DiagAddr, "dapi-address", "", "the 'host:port' for diagnostics api")
 	flag.StringVar(&env.LogFile, "log", "", "the log file destination")
+	flag.StringVar(&env.LogConfig, "log-config", "", "the log config file (json, yaml or toml), reloaded on change")
 	flag.BoolVar(&env.LogDisabled, "no-log", false, "disable the logger")
 	flag.BoolVarP(&env.LogHumanize, "log-humanize", "h", false, "humanize log messages")
 	flag.BoolVar(&env.LogEnableColor, "log-color", true, "enable colored log messages")
@@ -61,19 +65,53 @@ func initFlags(env *EnvFlags) {
 	}
 }
 
-func initLogging(env *EnvFlags) logconfig.LogInitResult {
-	logInitResult := logconfig.LogInitResult{}
-	if !env.LogDisabled {
-		logOpts := logconfig.DefaultOptions()
+// Flags that are explicitly set take precedence over the
+// log config file and the environment.
+func applyLogFlags(env *EnvFlags, logOpts *logconfig.Options) {
+	// Logs are not humanized by default, unless configured otherwise.
+	humanizeConfigured := env.LogConfig != "" || os.Getenv(logconfig.EnvLogHumanize) != ""
+	if flag.CommandLine.Changed("log-humanize") || !humanizeConfigured {
 		logOpts.Humanize = env.LogHumanize
-		if env.LogFile != "" {
-			logOpts.LogFile = env.LogFile
-		}
+	}
+	if env.LogFile != "" {
+		logOpts.LogFile = env.LogFile
+	}
+	if flag.CommandLine.Changed("log-color") {
 		logOpts.EnableColor = env.LogEnableColor
+	}
+	if flag.CommandLine.Changed("verbose") {
 		logOpts.VerbosityLevel = env.Verbosity
-		logconfig.Init(&logOpts, &logInitResult)
 	}
-	return logInitResult
+}
+
+// initLogging returns the result of the logging setup, and a func that
+// stops reloading it, to be called before the result is closed.
+func initLogging(env *EnvFlags) (*logconfig.LogInitResult, func(), error) {
+	logInitResult := &logconfig.LogInitResult{}
+	stop := func() {}
+	if env.LogDisabled {
+		return logInitResult, stop, nil
+	}
+	override := func(logOpts *logconfig.Options) {
+		applyLogFlags(env, logOpts)
+		logOpts.SlogDefault = true
+		logOpts.Hooks = append(logOpts.Hooks, log.VersionHook(app.Version))
+	}
+	if err := logconfig.Reload(env.LogConfig, override, logInitResult); err != nil {
+		return logInitResult, stop, err
+	}
+	for _, o := range logInitResult.Outputs {
+		for _, e := range o.Errors {
+			log.Warnf("logger: %v, falling back to %s", e, o.Filename)
+		}
+	}
+	if env.LogConfig != "" {
+		watchOpts := logconfig.DefaultWatchOpts(env.LogConfig)
+		watchOpts.Override = override
+		watchOpts.Result = logInitResult
+		stop = logconfig.Watch(&watchOpts)
+	}
+	return logInitResult, stop, nil
 }
 
 func printPackageHeader(versionOnly bool) {
@@ -104,7 +142,11 @@ func main() {
 		return
 	}
 
-	logInitResult := initLogging(&env)
+	logInitResult, stopWatch, err := initLogging(&env)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	log.Infof("listen-address: %s", env.Addr)
 
 	if env.DiagAddr != "" {
@@ -119,9 +161,15 @@ func main() {
 		go s2.Run()
 	}
 
+	logger := logInitResult.Logger
+	if env.LogConfig != "" {
+		// Use the global logger as it's replaced on every reload.
+		logger = nil
+	}
+
 	opts := httpservice.HandlerServiceOpts{
 		Addr:          env.Addr,
-		Logger:        logInitResult.Logger,
+		Logger:        logger,
 		WebRoot:       filepath.Clean(env.WebRoot),
 		Hosts:         env.Hosts,
 		CacheDir:      env.CertCacheDir,
@@ -139,9 +187,14 @@ func main() {
 		return
 	}
 
+	shutdownSignals := appx.ShutdownSignals
+	if env.LogConfig != "" {
+		// SIGHUP reloads the log config instead.
+		shutdownSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
 	appx.CreateShutdownHandler(func() {
 		_ = service.Stop(0)
-	}, appx.ShutdownSignals...)
+	}, shutdownSignals...)
 
 	err = service.Run()
 	if err != http.ErrServerClosed {
@@ -149,4 +202,7 @@ func main() {
 	}
 
 	log.Info("exit")
+	// The watcher may be replacing the result until it's stopped.
+	stopWatch()
+	logInitResult.Close()
 }